@@ -1,3 +1,4 @@
 provider "octal" {
-  # example configuration here
-}
\ No newline at end of file
+  config_path    = "~/.kube/config"
+  config_context = "my-context"
+}
@@ -0,0 +1,3 @@
+# Installations are imported by the namespace they're installed in and their instance id,
+# the value of their `app.kubernetes.io/instance` label.
+terraform import octal_cert_manager.cert_manager cert-manager/terraform-20220704182503000000000001
@@ -3,162 +3,71 @@ package resource_component
 import (
 	"context"
 	"fmt"
-
-	AdmissionV1 "k8s.io/api/admissionregistration/v1"
-	AppsV1 "k8s.io/api/apps/v1"
-	CoreV1 "k8s.io/api/core/v1"
-	RbacV1 "k8s.io/api/rbac/v1"
+	"sort"
+	"strings"
 
 	"github.com/dylanturn/terraform-provider-octal/internal/util"
-	"github.com/hashicorp/terraform-plugin-log/tflog"
-	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 )
 
 type Component interface {
 	GetName() string
-	GetDefaultDeployments(ctx context.Context, d *schema.ResourceData, meta interface{}) *[]AppsV1.Deployment
-	GetDefaultServices(ctx context.Context, d *schema.ResourceData, meta interface{}) *[]CoreV1.Service
-	GetDefaultServiceAccounts(ctx context.Context, d *schema.ResourceData, meta interface{}) *[]CoreV1.ServiceAccount
-	GetDefaultRoles(ctx context.Context, d *schema.ResourceData, meta interface{}) *[]RbacV1.Role
-	GetDefaultRoleBindings(ctx context.Context, d *schema.ResourceData, meta interface{}) *[]RbacV1.RoleBinding
-	GetDefaultClusterRoles(ctx context.Context, d *schema.ResourceData, meta interface{}) *[]RbacV1.ClusterRole
-	GetDefaultClusterRoleBindings(ctx context.Context, d *schema.ResourceData, meta interface{}) *[]RbacV1.ClusterRoleBinding
-	GetDefaultMutatingWebhookConfigurations(ctx context.Context, d *schema.ResourceData, meta interface{}) *[]AdmissionV1.MutatingWebhookConfiguration
-	GetDefaultValidatingWebhookConfigurations(ctx context.Context, d *schema.ResourceData, meta interface{}) *[]AdmissionV1.ValidatingWebhookConfiguration
+	// GetVersions lists the upstream releases the component ships manifests for.
+	GetVersions() []string
+	// GetObjects returns every object the component ships at a version, whatever its kind,
+	// with its manifests rendered from values.
+	GetObjects(ctx context.Context, version string, values Values) ([]unstructured.Unstructured, error)
 }
 
+// Values are what the manifests of a component are rendered with. Templates refer to them
+// from the top, e.g. `{{ .Namespace }}`.
+type Values map[string]interface{}
+
 type ResourceComponent struct {
-	Name                                    string
-	DeploymentManifests                     []string
-	ServiceManifests                        []string
-	ServiceAccountManifests                 []string
-	RoleManifests                           []string
-	RoleBindingManifests                    []string
-	ClusterRoleManifests                    []string
-	ClusterRoleBindingManifests             []string
-	MutatingWebhookConfigurationManifests   []string
-	ValidatingWebhookConfigurationManifests []string
-	CustomResourceDefinitionManifests       []string
+	Name string
+	// Manifests holds the manifests of every release, keyed by version, e.g. `1.8.2`.
+	Manifests map[string][]util.Manifest
 }
 
 func (component ResourceComponent) GetName() string {
 	return component.Name
 }
 
-func (component ResourceComponent) GetDefaultDeployments(ctx context.Context, d *schema.ResourceData, meta interface{}) *[]AppsV1.Deployment {
-	manifests := component.DeploymentManifests
-	objects := make([]AppsV1.Deployment, len(manifests))
-
-	for index, manifest := range manifests {
-		err := util.DecodeManifest([]byte(manifest)).Decode(&objects[index])
-		if err != nil {
-
-			tflog.Error(ctx, fmt.Sprintf("Failed to decode deployment for %s. Error: %s", component.Name, err.Error()))
-		}
-	}
-	return &objects
-}
-
-func (component ResourceComponent) GetDefaultServiceAccounts(ctx context.Context, d *schema.ResourceData, meta interface{}) *[]CoreV1.ServiceAccount {
-	manifests := component.ServiceAccountManifests
-	objects := make([]CoreV1.ServiceAccount, len(manifests))
-
-	for index, manifest := range manifests {
-		err := util.DecodeManifest([]byte(manifest)).Decode(&objects[index])
-		if err != nil {
-			tflog.Error(ctx, fmt.Sprintf("Failed to decode service-account for %s. Error: %s", component.Name, err.Error()))
-		}
-	}
-	return &objects
-}
-
-func (component ResourceComponent) GetDefaultServices(ctx context.Context, d *schema.ResourceData, meta interface{}) *[]CoreV1.Service {
-	manifests := component.ServiceManifests
-	objects := make([]CoreV1.Service, len(manifests))
-
-	for index, manifest := range manifests {
-		err := util.DecodeManifest([]byte(manifest)).Decode(&objects[index])
-		if err != nil {
-			tflog.Error(ctx, fmt.Sprintf("Failed to decode service for %s. Error: %s", component.Name, err.Error()))
-		}
-	}
-	return &objects
-}
-
-func (component ResourceComponent) GetDefaultRoles(ctx context.Context, d *schema.ResourceData, meta interface{}) *[]RbacV1.Role {
-	manifests := component.RoleManifests
-	objects := make([]RbacV1.Role, len(manifests))
-
-	for index, manifest := range manifests {
-		err := util.DecodeManifest([]byte(manifest)).Decode(&objects[index])
-		if err != nil {
-			tflog.Error(ctx, fmt.Sprintf("Failed to decode role for %s. Error: %s", component.Name, err.Error()))
-		}
-	}
-	return &objects
-}
-
-func (component ResourceComponent) GetDefaultRoleBindings(ctx context.Context, d *schema.ResourceData, meta interface{}) *[]RbacV1.RoleBinding {
-	manifests := component.RoleBindingManifests
-	objects := make([]RbacV1.RoleBinding, len(manifests))
-
-	for index, manifest := range manifests {
-		err := util.DecodeManifest([]byte(manifest)).Decode(&objects[index])
-		if err != nil {
-			tflog.Error(ctx, fmt.Sprintf("Failed to decode role-binding for %s. Error: %s", component.Name, err.Error()))
-		}
+func (component ResourceComponent) GetVersions() []string {
+	versions := make([]string, 0, len(component.Manifests))
+	for version := range component.Manifests {
+		versions = append(versions, version)
 	}
-	return &objects
+	sort.Strings(versions)
+	return versions
 }
 
-func (component ResourceComponent) GetDefaultClusterRoles(ctx context.Context, d *schema.ResourceData, meta interface{}) *[]RbacV1.ClusterRole {
-	manifests := component.ClusterRoleManifests
-	objects := make([]RbacV1.ClusterRole, len(manifests))
-
-	for index, manifest := range manifests {
-		err := util.DecodeManifest([]byte(manifest)).Decode(&objects[index])
-		if err != nil {
-			tflog.Error(ctx, fmt.Sprintf("Failed to decode cluster-role for %s. Error: %s", component.Name, err.Error()))
-		}
+// GetObjects renders every manifest of the version as a template, then decodes the objects
+// in them. A manifest that fails to render doesn't stop the others; the errors of all of
+// them are reported together, one per file.
+func (component ResourceComponent) GetObjects(ctx context.Context, version string, values Values) ([]unstructured.Unstructured, error) {
+	manifests, ok := component.Manifests[version]
+	if !ok {
+		return nil, fmt.Errorf("the %s component has no manifests for version %s", component.Name, version)
 	}
-	return &objects
-}
-
-func (component ResourceComponent) GetDefaultClusterRoleBindings(ctx context.Context, d *schema.ResourceData, meta interface{}) *[]RbacV1.ClusterRoleBinding {
-	manifests := component.ClusterRoleBindingManifests
-	objects := make([]RbacV1.ClusterRoleBinding, len(manifests))
 
-	for index, manifest := range manifests {
-		err := util.DecodeManifest([]byte(manifest)).Decode(&objects[index])
+	var objects []unstructured.Unstructured
+	var failures []string
+	for _, manifest := range manifests {
+		rendered, err := manifest.Render(values)
 		if err != nil {
-			tflog.Error(ctx, fmt.Sprintf("Failed to decode cluster-role-binding for %s. Error: %s", component.Name, err.Error()))
+			failures = append(failures, fmt.Sprintf("  - %s", err))
+			continue
 		}
-	}
-	return &objects
-}
-
-func (component ResourceComponent) GetDefaultMutatingWebhookConfigurations(ctx context.Context, d *schema.ResourceData, meta interface{}) *[]AdmissionV1.MutatingWebhookConfiguration {
-	manifests := component.MutatingWebhookConfigurationManifests
-	objects := make([]AdmissionV1.MutatingWebhookConfiguration, len(manifests))
-
-	for index, manifest := range manifests {
-		err := util.DecodeManifest([]byte(manifest)).Decode(&objects[index])
+		decoded, err := rendered.DecodeObjects()
 		if err != nil {
-			tflog.Error(ctx, fmt.Sprintf("Failed to decode MutatingWebhookConfiguration for %s. Error: %s", component.Name, err.Error()))
+			failures = append(failures, fmt.Sprintf("  - %s", err))
+			continue
 		}
+		objects = append(objects, decoded...)
 	}
-	return &objects
-}
-
-func (component ResourceComponent) GetDefaultValidatingWebhookConfigurations(ctx context.Context, d *schema.ResourceData, meta interface{}) *[]AdmissionV1.ValidatingWebhookConfiguration {
-	manifests := component.ValidatingWebhookConfigurationManifests
-	objects := make([]AdmissionV1.ValidatingWebhookConfiguration, len(manifests))
-
-	for index, manifest := range manifests {
-		err := util.DecodeManifest([]byte(manifest)).Decode(&objects[index])
-		if err != nil {
-			tflog.Error(ctx, fmt.Sprintf("Failed to decode ValidatingWebhookConfigurationManifests for %s. Error: %s", component.Name, err.Error()))
-		}
+	if len(failures) > 0 {
+		return nil, fmt.Errorf("failed to render the manifests of the %s component:\n%s", component.Name, strings.Join(failures, "\n"))
 	}
-	return &objects
+	return objects, nil
 }
@@ -0,0 +1,374 @@
+package octal
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/Masterminds/semver/v3"
+	resource_component "github.com/dylanturn/terraform-provider-octal/internal/component"
+	"github.com/dylanturn/terraform-provider-octal/internal/resources/namespace"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// bundleDefinition declares a component bundle. resourceBundle builds the `octal_<name>`
+// resource from it, so adding a bundle is mostly a matter of declaring one and listing it
+// in bundles.
+type bundleDefinition struct {
+	// Name identifies the bundle, e.g. `cert-manager`. It's the default `name` of an
+	// installation, and the resource type is named after it.
+	Name        string
+	Description string
+
+	// DefaultVersion is installed unless `version` says otherwise. The versions that can
+	// be installed are the releases every component embeds manifests for.
+	DefaultVersion string
+
+	// Components are the sub-components the bundle is made of. Each gets a block of the
+	// same name in the resource.
+	Components []bundleComponent
+
+	// Parameters are the settings of the bundle that patch its manifests.
+	Parameters []bundleParameter
+}
+
+// bundleComponent is one sub-component of a bundle, such as cert-manager's webhook.
+type bundleComponent struct {
+	Component   resource_component.Component
+	Description string
+	// Schema is the schema of the component's block.
+	Schema func() *schema.Resource
+
+	// err is why the component's manifests failed to load.
+	err error
+}
+
+// loadComponent declares a component, loading it with load. A component that fails to load
+// still gets its block, so the provider keeps its schema, and the error is reported whenever
+// the bundle is rendered.
+func loadComponent(load func() (resource_component.Component, error), description string, schema func() *schema.Resource) bundleComponent {
+	component, err := load()
+	return bundleComponent{Component: component, Description: description, Schema: schema, err: err}
+}
+
+// bundleParameter is a setting of a bundle. Its value is written to Path in every manifest
+// it selects through Component and Kind; either left empty selects every manifest.
+type bundleParameter struct {
+	Name        string
+	Type        schema.ValueType
+	Default     interface{}
+	Description string
+
+	Component string
+	Kind      string
+	// Path is the JSON path of the field the parameter sets, e.g. `.spec.replicas` or
+	// `.spec.template.spec.containers[0].image`.
+	Path string
+}
+
+// bundles is the registry of every bundle the provider offers.
+func bundles() []*bundleDefinition {
+	return []*bundleDefinition{
+		certManagerBundle(),
+	}
+}
+
+// bundleResources builds the provider's ResourcesMap from the registry.
+func bundleResources() map[string]*schema.Resource {
+	resources := map[string]*schema.Resource{}
+	for _, bundle := range bundles() {
+		resources[bundle.resourceType()] = resourceBundle(bundle)
+	}
+	return resources
+}
+
+// resourceType is the type of the bundle's resource, e.g. `octal_cert_manager`.
+func (b *bundleDefinition) resourceType() string {
+	return "octal_" + strings.ReplaceAll(b.Name, "-", "_")
+}
+
+// instanceLabel is the label every object of an installation carries its id in.
+func (b *bundleDefinition) instanceLabel() string {
+	return fmt.Sprintf("project-octal.io/%s-schema", b.Name)
+}
+
+// listOptions selects the objects of the installation with the given id.
+func (b *bundleDefinition) listOptions(resourceId string) metav1.ListOptions {
+	return metav1.ListOptions{
+		LabelSelector: labels.Set{
+			b.instanceLabel():            resourceId,
+			"app.kubernetes.io/instance": resourceId,
+		}.String(),
+	}
+}
+
+// versions lists the releases every component of the bundle ships, oldest first.
+func (b *bundleDefinition) versions() []string {
+	shipped := map[string]int{}
+	for _, component := range b.Components {
+		for _, version := range component.Component.GetVersions() {
+			shipped[version]++
+		}
+	}
+
+	var versions []string
+	for version, count := range shipped {
+		if count == len(b.Components) {
+			versions = append(versions, version)
+		}
+	}
+	sort.Slice(versions, func(i, j int) bool {
+		return compareVersions(versions[i], versions[j]) < 0
+	})
+	return versions
+}
+
+// resolveVersion returns the release `version` selects: the release itself, or the newest
+// release satisfying it when it's a constraint such as `~> 1.8`.
+func (b *bundleDefinition) resolveVersion(version string) (string, error) {
+	versions := b.versions()
+	for _, release := range versions {
+		if release == strings.TrimPrefix(version, "v") {
+			return release, nil
+		}
+	}
+
+	constraint, err := semver.NewConstraint(version)
+	if err != nil {
+		return "", fmt.Errorf("%q is neither a version of %s nor a version constraint: %s", version, b.Name, err)
+	}
+	for index := len(versions) - 1; index >= 0; index-- {
+		release, err := semver.NewVersion(versions[index])
+		if err == nil && constraint.Check(release) {
+			return versions[index], nil
+		}
+	}
+	return "", fmt.Errorf("none of the versions of %s satisfy %q. Available: %s", b.Name, version, strings.Join(versions, ", "))
+}
+
+// compareVersions orders releases by semantic version, and those that aren't one by name.
+func compareVersions(a string, b string) int {
+	versionA, errA := semver.NewVersion(a)
+	versionB, errB := semver.NewVersion(b)
+	if errA != nil || errB != nil {
+		return strings.Compare(a, b)
+	}
+	return versionA.Compare(versionB)
+}
+
+// renderedBy lists the attributes the rendered objects depend on: the values their templates
+// are rendered with, which also name and label them.
+func (b *bundleDefinition) renderedBy() []string {
+	keys := []string{"name", "namespace", "version"}
+	for _, parameter := range b.Parameters {
+		keys = append(keys, parameter.Name)
+	}
+	return keys
+}
+
+// objects collects every manifest of the bundle, starting with the namespace it's
+// installed into, and patches them with the bundle's parameters.
+func (b *bundleDefinition) objects(ctx context.Context, settings resourceSettings, meta interface{}) ([]bundleObject, error) {
+	var objects []bundleObject
+
+	for _, component := range b.Components {
+		if component.err != nil {
+			return nil, component.err
+		}
+	}
+
+	version, err := b.resolveVersion(settings.Get("version").(string))
+	if err != nil {
+		return nil, err
+	}
+
+	namespaceObject, err := namespace.GetDefaultNamespace(ctx)
+	if err != nil {
+		return nil, err
+	}
+	object, err := newBundleObject(b, version, "namespace", namespaceObject)
+	if err != nil {
+		return nil, err
+	}
+	objects = append(objects, object)
+
+	for _, component := range b.Components {
+		values := b.values(settings, version, component.Component.GetName())
+		manifests, err := component.Component.GetObjects(ctx, version, values)
+		if err != nil {
+			return nil, err
+		}
+		for i := range manifests {
+			object, err := newBundleObject(b, version, component.Component.GetName(), &manifests[i])
+			if err != nil {
+				return nil, err
+			}
+			objects = append(objects, object)
+		}
+	}
+
+	for _, parameter := range b.Parameters {
+		if err := parameter.patch(ctx, settings, objects); err != nil {
+			return nil, err
+		}
+	}
+
+	return objects, nil
+}
+
+// values are what the manifests of a component are rendered with:
+//
+//   - .Name, .Namespace and .Version: the installation's name, namespace and resolved version
+//   - .Instance: its id, which is empty until it's created
+//   - .Parameters: the values of the bundle's parameters, by name
+//   - .Component: the component's own block, as .Name, .Labels, .Annotations and .Image with
+//     .Repository, .Name, .Tag and .PullPolicy
+func (b *bundleDefinition) values(settings resourceSettings, version string, component string) resource_component.Values {
+	parameters := map[string]interface{}{}
+	for _, parameter := range b.Parameters {
+		parameters[parameter.Name] = settings.Get(parameter.Name)
+	}
+
+	config := map[string]interface{}{}
+	if block, ok := settings.Get(component).([]interface{}); ok && len(block) > 0 && block[0] != nil {
+		config = block[0].(map[string]interface{})
+	}
+	setting := func(key string) string {
+		value, _ := config[key].(string)
+		return value
+	}
+	labels, _ := config["labels"].(map[string]interface{})
+	annotations, _ := config["annotations"].(map[string]interface{})
+
+	return resource_component.Values{
+		"Name":       settings.Get("name"),
+		"Namespace":  settings.Get("namespace"),
+		"Version":    version,
+		"Instance":   settings.Id(),
+		"Parameters": parameters,
+		"Component": map[string]interface{}{
+			"Name":        component,
+			"Labels":      expandStringMap(labels),
+			"Annotations": expandStringMap(annotations),
+			"Image": map[string]interface{}{
+				"Repository": setting("image_repository"),
+				"Name":       setting("image_name"),
+				"Tag":        setting("image_tag"),
+				"PullPolicy": setting("image_pull_policy"),
+			},
+		},
+	}
+}
+
+// patch writes the parameter's value into the objects it selects. A parameter that selects
+// none of them would be accepted and do nothing, so that's an error.
+func (p bundleParameter) patch(ctx context.Context, settings resourceSettings, objects []bundleObject) error {
+	value := settings.Get(p.Name)
+	// Unstructured content only holds the types JSON decodes to.
+	if v, ok := value.(int); ok {
+		value = int64(v)
+	}
+
+	patched := 0
+	for _, object := range objects {
+		if p.Component != "" && object.component != p.Component {
+			continue
+		}
+		if p.Kind != "" && object.object.GetKind() != p.Kind {
+			continue
+		}
+		if err := setJSONPath(object.object.Object, p.Path, value); err != nil {
+			return fmt.Errorf("failed to set `%s` on %s %s: %s", p.Name, object.object.GetKind(), object.object.GetName(), err)
+		}
+		patched++
+	}
+	if patched == 0 {
+		return fmt.Errorf("`%s` selects none of the manifests (component %q, kind %q)", p.Name, p.Component, p.Kind)
+	}
+	return nil
+}
+
+// setJSONPath sets the field at path, creating the maps leading to it. Lists aren't
+// created; an index has to exist already.
+func setJSONPath(content map[string]interface{}, path string, value interface{}) error {
+	segments, err := parseJSONPath(path)
+	if err != nil {
+		return err
+	}
+
+	var current interface{} = content
+	for index, segment := range segments {
+		last := index == len(segments)-1
+
+		switch container := current.(type) {
+		case map[string]interface{}:
+			key, ok := segment.(string)
+			if !ok {
+				return fmt.Errorf("%s: expected a list, found a map", path)
+			}
+			if last {
+				container[key] = value
+				return nil
+			}
+			if _, exists := container[key]; !exists {
+				if _, nextIsIndex := segments[index+1].(int); nextIsIndex {
+					return fmt.Errorf("%s: the list %s doesn't exist", path, key)
+				}
+				container[key] = map[string]interface{}{}
+			}
+			current = container[key]
+
+		case []interface{}:
+			position, ok := segment.(int)
+			if !ok {
+				return fmt.Errorf("%s: expected a map, found a list", path)
+			}
+			if position >= len(container) {
+				return fmt.Errorf("%s: index %d is out of range", path, position)
+			}
+			if last {
+				container[position] = value
+				return nil
+			}
+			current = container[position]
+
+		default:
+			return fmt.Errorf("%s: can't descend into a %T", path, current)
+		}
+	}
+	return nil
+}
+
+// parseJSONPath splits a path like `.spec.containers[0].image` into map keys (strings) and
+// list indexes (ints).
+func parseJSONPath(path string) ([]interface{}, error) {
+	if !strings.HasPrefix(path, ".") {
+		return nil, fmt.Errorf("invalid path %q: it must start with a dot", path)
+	}
+
+	var segments []interface{}
+	for _, part := range strings.Split(path[1:], ".") {
+		key := part
+		var indexes []interface{}
+		if open := strings.Index(part, "["); open >= 0 {
+			key = part[:open]
+			for _, index := range strings.Split(strings.TrimSuffix(part[open+1:], "]"), "][") {
+				position, err := strconv.Atoi(index)
+				if err != nil || position < 0 {
+					return nil, fmt.Errorf("invalid path %q: bad index %q", path, index)
+				}
+				indexes = append(indexes, position)
+			}
+		}
+		if key == "" {
+			return nil, fmt.Errorf("invalid path %q: empty key", path)
+		}
+		segments = append(segments, key)
+		segments = append(segments, indexes...)
+	}
+	return segments, nil
+}
@@ -0,0 +1,276 @@
+package octal
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	resource_component "github.com/dylanturn/terraform-provider-octal/internal/component"
+	octal_schema "github.com/dylanturn/terraform-provider-octal/internal/schema"
+	"github.com/dylanturn/terraform-provider-octal/internal/util"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func testBundle() *bundleDefinition {
+	return &bundleDefinition{
+		Name:           "example-operator",
+		DefaultVersion: "1.0.0",
+		Components: []bundleComponent{
+			{
+				Component: resource_component.ResourceComponent{
+					Name: "operator",
+					Manifests: map[string][]util.Manifest{
+						"1.0.0": testManifests(
+							"apiVersion: apps/v1\nkind: Deployment\nmetadata:\n  name: operator\n"+
+								"spec:\n  template:\n    spec:\n      containers:\n      - name: operator\n        image: example/operator:1.0.0\n",
+							"apiVersion: v1\nkind: ServiceAccount\nmetadata:\n  name: operator\n",
+						),
+					},
+				},
+				Schema: func() *schema.Resource { return &schema.Resource{Schema: *octal_schema.ComponentSchema()} },
+			},
+		},
+		Parameters: []bundleParameter{
+			{Name: "replicas", Type: schema.TypeInt, Default: 2, Kind: "Deployment", Path: ".spec.replicas"},
+			{Name: "log_level", Type: schema.TypeString, Default: "info", Component: "operator", Kind: "Deployment", Path: ".spec.template.spec.containers[0].env.LOG_LEVEL"},
+		},
+	}
+}
+
+// testManifests wraps manifest contents as the files of a bundle directory.
+func testManifests(contents ...string) []util.Manifest {
+	manifests := make([]util.Manifest, len(contents))
+	for index, content := range contents {
+		manifests[index] = util.Manifest{Path: fmt.Sprintf("manifest-%d.yml", index), Content: []byte(content)}
+	}
+	return manifests
+}
+
+func TestResourceBundle(t *testing.T) {
+	bundle := testBundle()
+	resources := map[string]*schema.Resource{bundle.resourceType(): resourceBundle(bundle)}
+	resource, ok := resources["octal_example_operator"]
+	if !ok {
+		t.Fatalf("expected the resource to be named after the bundle, got %v", resources)
+	}
+	if err := resource.InternalValidate(nil, true); err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{"operator", "replicas", "log_level", "inventory"} {
+		if _, ok := resource.Schema[key]; !ok {
+			t.Errorf("expected the schema to have %q", key)
+		}
+	}
+
+	d := schema.TestResourceDataRaw(t, resource.Schema, map[string]interface{}{
+		"namespace": "operators",
+		"replicas":  3,
+	})
+	objects, err := bundle.objects(context.Background(), d, newTestApiClient())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(objects) != 3 {
+		t.Fatalf("expected the namespace and both manifests, got %d objects", len(objects))
+	}
+
+	deployment := objects[1].object
+	if replicas, _, _ := unstructured.NestedInt64(deployment.Object, "spec", "replicas"); replicas != 3 {
+		t.Errorf("expected 3 replicas, got %d", replicas)
+	}
+	containers, _, _ := unstructured.NestedSlice(deployment.Object, "spec", "template", "spec", "containers")
+	if level, _, _ := unstructured.NestedString(containers[0].(map[string]interface{}), "env", "LOG_LEVEL"); level != "info" {
+		t.Errorf("expected the default log level, got %q", level)
+	}
+	if _, found, _ := unstructured.NestedFieldNoCopy(objects[2].object.Object, "spec"); found {
+		t.Error("expected the ServiceAccount to be left alone")
+	}
+}
+
+// TestRegisteredBundles renders every version of every bundle the provider offers, so a
+// broken manifest or a parameter that patches nothing fails here rather than in a plan.
+func TestRegisteredBundles(t *testing.T) {
+	for _, bundle := range bundles() {
+		for _, component := range bundle.Components {
+			if component.err != nil {
+				t.Errorf("%s: %s", bundle.Name, component.err)
+			}
+		}
+		for _, version := range bundle.versions() {
+			d := schema.TestResourceDataRaw(t, resourceBundle(bundle).Schema, map[string]interface{}{
+				"namespace": "octal",
+				"version":   version,
+			})
+			if _, err := bundle.objects(context.Background(), d, newTestApiClient()); err != nil {
+				t.Errorf("%s %s: %s", bundle.Name, version, err)
+			}
+		}
+	}
+}
+
+func TestComponentLoadError(t *testing.T) {
+	bundle := testBundle()
+	bundle.Components = append(bundle.Components, loadComponent(func() (resource_component.Component, error) {
+		versions, err := util.ReadComponentManifests("broken", fstest.MapFS{"notes.txt": {}})
+		return resource_component.ResourceComponent{Name: "broken", Manifests: versions}, err
+	}, "", bundle.Components[0].Schema))
+
+	// The resource keeps its schema, and rendering it reports the error.
+	resource := resourceBundle(bundle)
+	if _, ok := resource.Schema["broken"]; !ok {
+		t.Errorf("expected a block for the component that failed to load, got %v", resource.Schema)
+	}
+	d := schema.TestResourceDataRaw(t, resource.Schema, map[string]interface{}{})
+	_, err := bundle.objects(context.Background(), d, newTestApiClient())
+	if err == nil || !strings.Contains(err.Error(), "failed to load the broken manifests") {
+		t.Errorf("expected the load error when rendering, got %v", err)
+	}
+}
+
+func TestParameterSelectsNothing(t *testing.T) {
+	bundle := testBundle()
+	bundle.Parameters[0].Kind = "StatefulSet"
+
+	d := schema.TestResourceDataRaw(t, resourceBundle(bundle).Schema, map[string]interface{}{})
+	_, err := bundle.objects(context.Background(), d, newTestApiClient())
+	if err == nil || !strings.Contains(err.Error(), "`replicas` selects none of the manifests") {
+		t.Errorf("expected an error for a parameter that selects nothing, got %v", err)
+	}
+
+	if diags := resourceBundle(bundle).CreateContext(context.Background(), d, newTestApiClient()); !diags.HasError() {
+		t.Error("expected creating the resource to fail")
+	}
+	if d.Id() != "" {
+		t.Errorf("expected no id after failing to render, got %q", d.Id())
+	}
+}
+
+func TestSetJSONPath(t *testing.T) {
+	content := map[string]interface{}{
+		"spec": map[string]interface{}{
+			"containers": []interface{}{map[string]interface{}{"name": "operator"}},
+		},
+	}
+
+	if err := setJSONPath(content, ".spec.containers[0].image", "example/operator:1.1.0"); err != nil {
+		t.Fatal(err)
+	}
+	if image, _, _ := unstructured.NestedString(content["spec"].(map[string]interface{})["containers"].([]interface{})[0].(map[string]interface{}), "image"); image != "example/operator:1.1.0" {
+		t.Errorf("unexpected image %q", image)
+	}
+
+	for _, path := range []string{"spec.replicas", ".spec.containers[1].image", ".spec.volumes[0].name", ".spec.containers[x]", ".spec..replicas"} {
+		if err := setJSONPath(content, path, "value"); err == nil {
+			t.Errorf("expected an error for %q", path)
+		}
+	}
+}
+
+func TestResolveVersion(t *testing.T) {
+	release := testManifests("apiVersion: v1\nkind: ServiceAccount\nmetadata:\n  name: operator\n")
+	bundle := testBundle()
+	bundle.Components[0].Component = resource_component.ResourceComponent{
+		Name: "operator",
+		Manifests: map[string][]util.Manifest{
+			"1.8.2":  release,
+			"1.9.1":  release,
+			"1.10.0": release,
+			"2.0.0":  release,
+		},
+	}
+	bundle.Components = append(bundle.Components, bundleComponent{
+		Component: resource_component.ResourceComponent{
+			Name:      "webhook",
+			Manifests: map[string][]util.Manifest{"1.8.2": release, "1.9.1": release, "1.10.0": release},
+		},
+	})
+
+	if versions := bundle.versions(); !reflect.DeepEqual(versions, []string{"1.8.2", "1.9.1", "1.10.0"}) {
+		t.Errorf("expected the versions every component ships, newest last, got %v", versions)
+	}
+
+	for version, expected := range map[string]string{
+		"1.9.1":   "1.9.1",
+		"v1.8.2":  "1.8.2",
+		"~> 1.8":  "1.8.2",
+		"~> 1":    "1.10.0",
+		">= 1.9":  "1.10.0",
+		"< 1.10":  "1.9.1",
+		"1.9.x":   "1.9.1",
+		"^1.8.0":  "1.10.0",
+		"=1.10.0": "1.10.0",
+	} {
+		resolved, err := bundle.resolveVersion(version)
+		if err != nil || resolved != expected {
+			t.Errorf("expected %q to resolve to %s, got %q (%v)", version, expected, resolved, err)
+		}
+	}
+
+	for _, version := range []string{"2.0.0", "~> 1.11", "latest"} {
+		if _, err := bundle.resolveVersion(version); err == nil {
+			t.Errorf("expected %q not to resolve", version)
+		}
+		if _, es := validateBundleVersion(bundle)(version, "version"); len(es) == 0 {
+			t.Errorf("expected %q to be rejected", version)
+		}
+	}
+}
+
+func TestBundleTemplates(t *testing.T) {
+	bundle := testBundle()
+	for index := range bundle.Parameters {
+		bundle.Parameters[index].Kind = "ConfigMap"
+		bundle.Parameters[index].Path = ".spec." + bundle.Parameters[index].Name
+	}
+	bundle.Components[0].Component = resource_component.ResourceComponent{
+		Name: "operator",
+		Manifests: map[string][]util.Manifest{
+			"1.0.0": testManifests(`apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: {{ .Name }}-{{ .Component.Name }}
+  namespace: {{ .Namespace }}
+  labels: {{ toJson .Component.Labels }}
+data:
+  image: {{ .Component.Image.Repository | default "example" }}/operator:{{ .Version }}
+  replicas: {{ .Parameters.replicas | quote }}
+  level: {{ .Parameters.log_level | upper }}
+`),
+		},
+	}
+
+	d := schema.TestResourceDataRaw(t, resourceBundle(bundle).Schema, map[string]interface{}{
+		"name":      "platform",
+		"namespace": "operators",
+		"log_level": "debug",
+		"operator": []interface{}{map[string]interface{}{
+			"labels": map[string]interface{}{"team": "platform"},
+		}},
+	})
+	objects, err := bundle.objects(context.Background(), d, newTestApiClient())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	config := objects[1].object
+	if config.GetName() != "platform-operator" || config.GetNamespace() != "operators" || config.GetLabels()["team"] != "platform" {
+		t.Errorf("unexpected metadata %v", config.Object["metadata"])
+	}
+	data, _, _ := unstructured.NestedStringMap(config.Object, "data")
+	if !reflect.DeepEqual(data, map[string]string{"image": "example/operator:1.0.0", "replicas": "2", "level": "DEBUG"}) {
+		t.Errorf("unexpected data %v", data)
+	}
+
+	bundle.Components[0].Component.(resource_component.ResourceComponent).Manifests["1.0.0"] = testManifests(
+		"kind: {{ .Kind }}\n",
+		"kind: {{ .Name\n",
+	)
+	_, err = bundle.objects(context.Background(), d, newTestApiClient())
+	if err == nil || !strings.Contains(err.Error(), "manifest-0.yml") || !strings.Contains(err.Error(), "manifest-1.yml") {
+		t.Errorf("expected the error of each manifest, got %v", err)
+	}
+}
@@ -0,0 +1,206 @@
+package octal
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	apimachineryschema "k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/discovery/cached/memory"
+	"k8s.io/client-go/dynamic"
+	restclient "k8s.io/client-go/rest"
+	"k8s.io/client-go/restmapper"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+)
+
+// errConfigUnknown is returned when a client is requested while the provider block still
+// contains values that won't be known until apply, e.g. the endpoint of a cluster created
+// in the same run.
+var errConfigUnknown = errors.New("the provider configuration depends on values that are not known yet, so the cluster can't be reached during this operation")
+
+// apiClient holds the connection settings resolved from the provider block. The clients
+// themselves are only built the first time a resource asks for them, so configuring the
+// provider never talks to the cluster.
+type apiClient struct {
+	config *restclient.Config
+
+	// providerSettings are kept apart from config so resources that bring their own
+	// connection still honour them when the provider's own connection isn't known yet.
+	providerSettings
+
+	// overrides holds the clients built for resources with their own `cluster` or
+	// `impersonate` block. It's shared with those clients so a resource resolves to the
+	// same client however it's reached.
+	overrides *clientCache
+
+	dynamicClientOnce sync.Once
+	dynamicClient     dynamic.Interface
+	dynamicClientErr  error
+
+	restMapperOnce sync.Once
+	restMapper     apimeta.RESTMapper
+	restMapperErr  error
+}
+
+// providerSettings are the provider-level options that apply to every client.
+type providerSettings struct {
+	userAgent string
+
+	// fieldManager is the name the provider's server-side applies are recorded under.
+	fieldManager string
+	// forceConflicts takes ownership of fields managed by someone else instead of failing.
+	forceConflicts bool
+	// parallelism is how many objects of a phase are applied or deleted at once.
+	parallelism int
+
+	// qps, burst, timeout and proxy tune the connection to whichever cluster a client
+	// talks to.
+	qps     float32
+	burst   int
+	timeout time.Duration
+	proxy   func(*http.Request) (*url.URL, error)
+}
+
+// tune applies the provider's rate limits, timeout and proxy to a connection.
+func (s providerSettings) tune(config *restclient.Config) {
+	config.QPS = s.qps
+	config.Burst = s.burst
+	config.Timeout = s.timeout
+	config.Proxy = s.proxy
+}
+
+type clientCache struct {
+	sync.Mutex
+	clients map[string]*apiClient
+}
+
+func newApiClient(config *restclient.Config, settings providerSettings) *apiClient {
+	return &apiClient{
+		config:           config,
+		providerSettings: settings,
+		overrides:        &clientCache{clients: map[string]*apiClient{}},
+	}
+}
+
+// DynamicClient returns the client used to work with arbitrary kinds, building it on first use.
+func (c *apiClient) DynamicClient() (dynamic.Interface, error) {
+	c.dynamicClientOnce.Do(func() {
+		if c.config == nil {
+			c.dynamicClientErr = errConfigUnknown
+			return
+		}
+		dynamicClient, err := dynamic.NewForConfig(c.config)
+		if err != nil {
+			c.dynamicClientErr = fmt.Errorf("failed to create the Kubernetes dynamic client: %s", err)
+			return
+		}
+		c.dynamicClient = dynamicClient
+	})
+	return c.dynamicClient, c.dynamicClientErr
+}
+
+// RESTMapper returns the mapper that resolves kinds to API resources, building it on first
+// use. It's backed by discovery, cached for the life of the client, so kinds the provider
+// has no Go types for, such as CRDs, resolve too.
+func (c *apiClient) RESTMapper() (apimeta.RESTMapper, error) {
+	c.restMapperOnce.Do(func() {
+		if c.config == nil {
+			c.restMapperErr = errConfigUnknown
+			return
+		}
+		discoveryClient, err := discovery.NewDiscoveryClientForConfig(c.config)
+		if err != nil {
+			c.restMapperErr = fmt.Errorf("failed to create the Kubernetes discovery client: %s", err)
+			return
+		}
+		c.restMapper = restmapper.NewDeferredDiscoveryRESTMapper(memory.NewMemCacheClient(discoveryClient))
+	})
+	return c.restMapper, c.restMapperErr
+}
+
+// connectionOverride is everything a resource can override about how the provider
+// connects. Two resources with equal overrides share a client.
+type connectionOverride struct {
+	Host                 string
+	Token                string
+	ClusterCACertificate string
+	Exec                 *clientcmdapi.ExecConfig
+	Impersonate          *restclient.ImpersonationConfig
+}
+
+func (o connectionOverride) key() string {
+	raw, _ := json.Marshal(o)
+	return fmt.Sprintf("%x", sha256.Sum256(raw))
+}
+
+// resourceClient returns the client a resource should talk to the cluster with. Resources
+// that set their own `cluster` or `impersonate` block get a client of their own, cached per
+// distinct connection, that otherwise keeps the provider's client settings.
+func resourceClient(d resourceSettings, meta interface{}) (*apiClient, error) {
+	client := meta.(*apiClient)
+
+	override := connectionOverride{
+		Impersonate: expandImpersonationConfig(d.Get("impersonate").([]interface{})),
+	}
+	if v, ok := d.Get("cluster").([]interface{}); ok && len(v) > 0 && v[0] != nil {
+		cluster := v[0].(map[string]interface{})
+		override.Host = cluster["host"].(string)
+		override.Token = cluster["token"].(string)
+		override.ClusterCACertificate = cluster["cluster_ca_certificate"].(string)
+		override.Exec = expandExecConfig(cluster["exec"].([]interface{}))
+	}
+
+	if override.Host == "" && (override.Impersonate == nil || client.config == nil) {
+		return client, nil
+	}
+
+	client.overrides.Lock()
+	defer client.overrides.Unlock()
+
+	key := override.key()
+	if cached, ok := client.overrides.clients[key]; ok {
+		return cached, nil
+	}
+
+	var config *restclient.Config
+	if override.Host != "" {
+		hostURL, _, err := restclient.DefaultServerURL(override.Host, "", apimachineryschema.GroupVersion{}, true)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse cluster host: %s", err)
+		}
+
+		config = &restclient.Config{
+			Host:         hostURL.String(),
+			BearerToken:  override.Token,
+			ExecProvider: override.Exec,
+			UserAgent:    client.userAgent,
+			TLSClientConfig: restclient.TLSClientConfig{
+				CAData: []byte(override.ClusterCACertificate),
+			},
+		}
+		// The provider's tuning carries over, but not its impersonation: that identity is
+		// meant for the provider's own cluster. Only the resource's `impersonate` applies.
+		client.providerSettings.tune(config)
+	} else {
+		config = restclient.CopyConfig(client.config)
+	}
+	if override.Impersonate != nil {
+		config.Impersonate = *override.Impersonate
+	}
+
+	cached := &apiClient{
+		config:           config,
+		providerSettings: client.providerSettings,
+		overrides:        client.overrides,
+	}
+	client.overrides.clients[key] = cached
+
+	return cached, nil
+}
@@ -1,17 +1,27 @@
 package octal
 
 import (
+	"bytes"
 	"context"
 	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
 
+	"github.com/hashicorp/terraform-plugin-log/tflog"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
-	"k8s.io/client-go/kubernetes"
+	apimachineryschema "k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/client-go/rest"
-	restclient "k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
 	"k8s.io/client-go/util/homedir"
 )
 
@@ -34,69 +44,589 @@ func init() {
 func New(version string) func() *schema.Provider {
 	return func() *schema.Provider {
 		p := &schema.Provider{
-			ResourcesMap: map[string]*schema.Resource{
-				"octal_cert_manager": resourceOctalCertManager(),
+			Schema: map[string]*schema.Schema{
+				"host": {
+					Type:        schema.TypeString,
+					Optional:    true,
+					DefaultFunc: schema.EnvDefaultFunc("KUBE_HOST", ""),
+					Description: "The hostname (in form of URI) of the Kubernetes API server. Can be sourced from `KUBE_HOST`.",
+				},
+				"token": {
+					Type:        schema.TypeString,
+					Optional:    true,
+					Sensitive:   true,
+					DefaultFunc: schema.EnvDefaultFunc("KUBE_TOKEN", ""),
+					Description: "Token used to authenticate to the Kubernetes API server. Can be sourced from `KUBE_TOKEN`.",
+				},
+				"insecure": {
+					Type:        schema.TypeBool,
+					Optional:    true,
+					DefaultFunc: schema.EnvDefaultFunc("KUBE_INSECURE", false),
+					Description: "Whether the server should be accessed without verifying the TLS certificate. Can be sourced from `KUBE_INSECURE`.",
+				},
+				"cluster_ca_certificate": {
+					Type:        schema.TypeString,
+					Optional:    true,
+					DefaultFunc: schema.EnvDefaultFunc("KUBE_CLUSTER_CA_CERT_DATA", ""),
+					Description: "PEM-encoded root certificates bundle for TLS authentication. Can be sourced from `KUBE_CLUSTER_CA_CERT_DATA`.",
+				},
+				"client_certificate": {
+					Type:        schema.TypeString,
+					Optional:    true,
+					DefaultFunc: schema.EnvDefaultFunc("KUBE_CLIENT_CERT_DATA", ""),
+					Description: "PEM-encoded client certificate for TLS authentication. Can be sourced from `KUBE_CLIENT_CERT_DATA`.",
+				},
+				"client_key": {
+					Type:        schema.TypeString,
+					Optional:    true,
+					Sensitive:   true,
+					DefaultFunc: schema.EnvDefaultFunc("KUBE_CLIENT_KEY_DATA", ""),
+					Description: "PEM-encoded client certificate key for TLS authentication. Can be sourced from `KUBE_CLIENT_KEY_DATA`.",
+				},
+				"config_path": {
+					Type:          schema.TypeString,
+					Optional:      true,
+					DefaultFunc:   schema.EnvDefaultFunc("KUBE_CONFIG_PATH", nil),
+					Description:   "Path to the kube config file. Can be sourced from `KUBE_CONFIG_PATH`.",
+					ConflictsWith: []string{"config_paths", "kubeconfig"},
+				},
+				"config_paths": {
+					Type:          schema.TypeList,
+					Optional:      true,
+					Elem:          &schema.Schema{Type: schema.TypeString},
+					Description:   "A list of paths to kube config files. Can be sourced from `KUBE_CONFIG_PATHS`.",
+					ConflictsWith: []string{"config_path", "kubeconfig"},
+				},
+				"kubeconfig": {
+					Type:          schema.TypeString,
+					Optional:      true,
+					Sensitive:     true,
+					DefaultFunc:   schema.EnvDefaultFunc("KUBE_CONFIG_DATA", nil),
+					Description:   "The raw contents of a kube config file. Can be sourced from `KUBE_CONFIG_DATA`.",
+					ConflictsWith: []string{"config_path", "config_paths"},
+				},
+				"config_context": {
+					Type:        schema.TypeString,
+					Optional:    true,
+					DefaultFunc: schema.EnvDefaultFunc("KUBE_CTX", ""),
+					Description: "The kube config context to use. Can be sourced from `KUBE_CTX`.",
+				},
+				"config_context_auth_info": {
+					Type:        schema.TypeString,
+					Optional:    true,
+					DefaultFunc: schema.EnvDefaultFunc("KUBE_CTX_AUTH_INFO", ""),
+					Description: "The kube config user to use in place of the one named by the context. Can be sourced from `KUBE_CTX_AUTH_INFO`.",
+				},
+				"config_context_cluster": {
+					Type:        schema.TypeString,
+					Optional:    true,
+					DefaultFunc: schema.EnvDefaultFunc("KUBE_CTX_CLUSTER", ""),
+					Description: "The kube config cluster to use in place of the one named by the context. Can be sourced from `KUBE_CTX_CLUSTER`.",
+				},
+				"exec": {
+					Type:        schema.TypeList,
+					Optional:    true,
+					MaxItems:    1,
+					Description: "Run a credential plugin, such as `aws-iam-authenticator`, `gke-gcloud-auth-plugin` or `kubelogin`, to obtain short-lived credentials. The plugin is invoked again whenever the credentials expire.",
+					Elem:        execSchema(),
+				},
+				"impersonate": {
+					Type:        schema.TypeList,
+					Optional:    true,
+					MaxItems:    1,
+					Description: "Impersonate another user, service account or group for every request made by the provider.",
+					Elem:        impersonateSchema(),
+				},
+				"qps": {
+					Type:         schema.TypeFloat,
+					Optional:     true,
+					Default:      50,
+					Description:  "The maximum number of requests per second the provider sends to the API server.",
+					ValidateFunc: validateFloatGreaterThan(0),
+				},
+				"burst": {
+					Type:         schema.TypeInt,
+					Optional:     true,
+					Default:      100,
+					Description:  "The number of requests the provider may send in a burst above `qps`.",
+					ValidateFunc: validatePositiveInteger,
+				},
+				"request_timeout": {
+					Type:         schema.TypeString,
+					Optional:     true,
+					DefaultFunc:  schema.EnvDefaultFunc("KUBE_REQUEST_TIMEOUT", ""),
+					Description:  "How long to wait for a single request to the API server, e.g. `30s`. Zero means no timeout. Can be sourced from `KUBE_REQUEST_TIMEOUT`.",
+					ValidateFunc: validateDuration,
+				},
+				"proxy_url": {
+					Type:         schema.TypeString,
+					Optional:     true,
+					DefaultFunc:  schema.EnvDefaultFunc("KUBE_PROXY_URL", ""),
+					Description:  "URL of the HTTP(S) or SOCKS5 proxy to reach the API server through. Can be sourced from `KUBE_PROXY_URL`.",
+					ValidateFunc: validateProxyURL,
+				},
+				"tls_server_name": {
+					Type:        schema.TypeString,
+					Optional:    true,
+					DefaultFunc: schema.EnvDefaultFunc("KUBE_TLS_SERVER_NAME", ""),
+					Description: "The server name to verify the API server's certificate against, when it differs from `host`. Can be sourced from `KUBE_TLS_SERVER_NAME`.",
+				},
+				"field_manager": {
+					Type:        schema.TypeString,
+					Optional:    true,
+					Default:     "terraform-provider-octal",
+					Description: "The field manager name server-side applies are recorded under.",
+				},
+				"force_conflicts": {
+					Type:        schema.TypeBool,
+					Optional:    true,
+					Default:     false,
+					Description: "Take ownership of fields that another field manager, such as a controller or `kubectl`, has set instead of failing the apply.",
+				},
+				"parallelism": {
+					Type:         schema.TypeInt,
+					Optional:     true,
+					Default:      10,
+					Description:  "How many objects that don't depend on each other are applied or deleted at once. Requests still keep to `qps` and `burst`.",
+					ValidateFunc: validatePositiveInteger,
+				},
 			},
+			ResourcesMap: bundleResources(),
 		}
 
-		p.ConfigureContextFunc = configure(version, p)
+		unknown := &unknownAttributes{names: map[string]bool{}}
+		for _, name := range connectionAttributes {
+			unknown.track(name, p.Schema[name])
+		}
+		p.ConfigureContextFunc = configure(version, p, unknown)
 
 		return p
 	}
 }
 
-type apiClient struct {
-	// Add whatever fields, client or connection info, etc. here
-	// you would need to setup to communicate with the upstream
-	// API.
-	config    *restclient.Config
-	clientset *kubernetes.Clientset
+// connectionAttributes are the attributes of the provider block that decide which cluster
+// it connects to, and as whom.
+var connectionAttributes = []string{
+	"host", "token", "cluster_ca_certificate", "client_certificate", "client_key",
+	"config_path", "config_paths", "kubeconfig", "config_context", "config_context_auth_info",
+	"config_context_cluster", "exec", "impersonate",
+}
+
+// unknownValue is how the SDK represents a value that's only known after apply.
+const unknownValue = "74D93920-ED26-11E3-AC10-0800200C9A66"
+
+// unknownAttributes records which connection attributes were unknown when the provider was
+// configured. The SDK reads unknown values as empty strings when configuring a provider, and
+// doesn't hand over the raw configuration, so the only place they can be told apart from
+// unset ones is the StateFunc it runs over every value while doing so.
+type unknownAttributes struct {
+	sync.Mutex
+	names map[string]bool
 }
 
-func configure(version string, p *schema.Provider) func(context.Context, *schema.ResourceData) (interface{}, diag.Diagnostics) {
-	return func(context.Context, *schema.ResourceData) (interface{}, diag.Diagnostics) {
-		// Setup a User-Agent for your API client (replace the octal name for yours):
-		// userAgent := p.UserAgent("terraform-octal-scaffolding", version)
-		// TODO: myClient.UserAgent = userAgent
+// track records attribute, and every string nested in it, as unknown whenever the SDK
+// passes it the unknown value.
+func (u *unknownAttributes) track(name string, attribute *schema.Schema) {
+	switch elem := attribute.Elem.(type) {
+	case *schema.Resource:
+		for _, nested := range elem.Schema {
+			u.track(name, nested)
+		}
+		return
+	case *schema.Schema:
+		u.track(name, elem)
+		return
+	}
+	if attribute.Type != schema.TypeString {
+		return
+	}
 
-		clientApi := apiClient{
-			config:    getKubeConfig(),
-			clientset: GetKubeClient(),
+	attribute.StateFunc = func(v interface{}) string {
+		value := v.(string)
+		if value == unknownValue {
+			u.Lock()
+			u.names[name] = true
+			u.Unlock()
 		}
+		return value
+	}
+}
 
-		return &clientApi, nil
+// take returns the attributes found unknown since it was last called.
+func (u *unknownAttributes) take() []string {
+	u.Lock()
+	defer u.Unlock()
+
+	var names []string
+	for name := range u.names {
+		names = append(names, name)
 	}
+	sort.Strings(names)
+	u.names = map[string]bool{}
+	return names
 }
 
-func getKubeConfig() *rest.Config {
-	var config *rest.Config
+func configure(version string, p *schema.Provider, unknown *unknownAttributes) func(context.Context, *schema.ResourceData) (interface{}, diag.Diagnostics) {
+	return func(ctx context.Context, d *schema.ResourceData) (interface{}, diag.Diagnostics) {
+		settings := providerSettings{
+			// Identify ourselves in the API server's audit log.
+			userAgent:      p.UserAgent("terraform-provider-octal", version),
+			fieldManager:   d.Get("field_manager").(string),
+			forceConflicts: d.Get("force_conflicts").(bool),
+			parallelism:    d.Get("parallelism").(int),
+		}
+		if err := expandClientTuning(d, &settings); err != nil {
+			return nil, diag.Diagnostics{{
+				Severity: diag.Error,
+				Summary:  "Invalid client settings",
+				Detail:   err.Error(),
+			}}
+		}
+
+		// When the provider block references something that's only created during apply
+		// we can't resolve the connection yet. Hand back a client without a config; resources
+		// get errConfigUnknown if they try to reach the cluster before it's known.
+		if names := unknown.take(); len(names) > 0 {
+			tflog.Warn(ctx, fmt.Sprintf("The provider's %s aren't known yet, deferring client initialization", strings.Join(names, ", ")))
+			return newApiClient(nil, settings), nil
+		}
 
-	kubeConfigPath := filepath.Join(homedir.HomeDir(), ".kube", "config")
-	if _, err := os.Stat(kubeConfigPath); err == nil {
-		config, err = clientcmd.BuildConfigFromFlags("", kubeConfigPath)
+		config, err := getKubeConfig(d)
 		if err != nil {
-			panic(err)
+			return nil, diag.Diagnostics{{
+				Severity: diag.Error,
+				Summary:  "Invalid cluster connection settings",
+				Detail:   err.Error(),
+			}}
+		}
+		if impersonate := expandImpersonationConfig(d.Get("impersonate").([]interface{})); impersonate != nil {
+			config.Impersonate = *impersonate
 		}
 
-	} else if errors.Is(err, os.ErrNotExist) {
-		config = &rest.Config{
-			Host:            "https://" + os.Getenv("KUBERNETES_SERVICE_HOST") + ":" + os.Getenv("KUBERNETES_SERVICE_PORT"),
-			BearerTokenFile: "/run/secrets/kubernetes.io/serviceaccount/token",
-			TLSClientConfig: rest.TLSClientConfig{
-				Insecure: false,
-				CAFile:   "/run/secrets/kubernetes.io/serviceaccount/ca.crt",
-			},
+		config.UserAgent = settings.userAgent
+		settings.tune(config)
+		if v := d.Get("tls_server_name").(string); v != "" {
+			config.TLSClientConfig.ServerName = v
 		}
-	}
 
-	return config
+		return newApiClient(config, settings), nil
+	}
 }
 
-func GetKubeClient() *kubernetes.Clientset {
-	config := getKubeConfig()
-	clientset, err := kubernetes.NewForConfig(config)
+// getKubeConfig builds the rest config from the provider block. Explicit kube config
+// files or content take precedence, the inline connection attributes override whatever
+// they load, and with nothing configured we fall back to ~/.kube/config or the in-cluster
+// service account.
+func getKubeConfig(d *schema.ResourceData) (*rest.Config, error) {
+	overrides := &clientcmd.ConfigOverrides{}
+	loader := &clientcmd.ClientConfigLoadingRules{}
+
+	configPaths := []string{}
+	if v, ok := d.Get("config_path").(string); ok && v != "" {
+		configPaths = []string{v}
+	} else if v, ok := d.Get("config_paths").([]interface{}); ok && len(v) > 0 {
+		for _, p := range v {
+			configPaths = append(configPaths, p.(string))
+		}
+	} else if v := os.Getenv("KUBE_CONFIG_PATHS"); v != "" {
+		configPaths = filepath.SplitList(v)
+	}
+
+	rawKubeConfig := d.Get("kubeconfig").(string)
+	host := d.Get("host").(string)
+
+	if len(configPaths) == 0 && rawKubeConfig == "" && host == "" {
+		kubeConfigPath := filepath.Join(homedir.HomeDir(), ".kube", "config")
+		if _, err := os.Stat(kubeConfigPath); err == nil {
+			configPaths = []string{kubeConfigPath}
+		} else if errors.Is(err, os.ErrNotExist) {
+			return inClusterConfig(expandExecConfig(d.Get("exec").([]interface{})))
+		}
+	}
+
+	if len(configPaths) > 0 {
+		expandedPaths := []string{}
+		for _, p := range configPaths {
+			expandedPaths = append(expandedPaths, expandHomeDir(p))
+		}
+		loader.Precedence = expandedPaths
+	}
+
+	if v := d.Get("config_context").(string); v != "" {
+		overrides.CurrentContext = v
+	}
+	if v := d.Get("config_context_auth_info").(string); v != "" {
+		overrides.Context.AuthInfo = v
+	}
+	if v := d.Get("config_context_cluster").(string); v != "" {
+		overrides.Context.Cluster = v
+	}
+
+	overrides.ClusterInfo.InsecureSkipTLSVerify = d.Get("insecure").(bool)
+	if v := d.Get("cluster_ca_certificate").(string); v != "" {
+		overrides.ClusterInfo.CertificateAuthorityData = bytes.NewBufferString(v).Bytes()
+	}
+	if v := d.Get("client_certificate").(string); v != "" {
+		overrides.AuthInfo.ClientCertificateData = bytes.NewBufferString(v).Bytes()
+	}
+	if v := d.Get("client_key").(string); v != "" {
+		overrides.AuthInfo.ClientKeyData = bytes.NewBufferString(v).Bytes()
+	}
+	if v := d.Get("token").(string); v != "" {
+		overrides.AuthInfo.Token = v
+	}
+	if exec := expandExecConfig(d.Get("exec").([]interface{})); exec != nil {
+		overrides.AuthInfo.Exec = exec
+	}
+	if host != "" {
+		// Server has to be the complete address of the Kubernetes cluster (scheme://hostname:port), not just the hostname,
+		// because `overrides` are processed too late to be taken into account by `defaultServerUrlFor()`.
+		// This basically replicates what defaultServerUrlFor() does with config but for overrides,
+		// see https://github.com/kubernetes/client-go/blob/v12.0.0/rest/url_utils.go#L85-L87
+		hasCA := len(overrides.ClusterInfo.CertificateAuthorityData) != 0
+		hasCert := len(overrides.AuthInfo.ClientCertificateData) != 0
+		defaultTLS := hasCA || hasCert || overrides.ClusterInfo.InsecureSkipTLSVerify
+		hostURL, _, err := rest.DefaultServerURL(host, "", apimachineryschema.GroupVersion{}, defaultTLS)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse host: %s", err)
+		}
+		overrides.ClusterInfo.Server = hostURL.String()
+	}
+
+	var clientConfig clientcmd.ClientConfig
+	if rawKubeConfig != "" {
+		apiConfig, err := clientcmd.Load([]byte(rawKubeConfig))
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse kubeconfig: %s", err)
+		}
+		clientConfig = clientcmd.NewNonInteractiveClientConfig(*apiConfig, overrides.CurrentContext, overrides, nil)
+	} else {
+		clientConfig = clientcmd.NewNonInteractiveDeferredLoadingClientConfig(loader, overrides)
+	}
+
+	config, err := clientConfig.ClientConfig()
 	if err != nil {
-		panic(err.Error())
+		return nil, fmt.Errorf("failed to initialize kubernetes configuration: %s", err)
+	}
+
+	return config, nil
+}
+
+// expandClientTuning reads the rate limits, timeout and proxy from the provider block. They
+// tune every client, including those of resources that connect to a cluster of their own.
+func expandClientTuning(d *schema.ResourceData, settings *providerSettings) error {
+	settings.qps = float32(d.Get("qps").(float64))
+	settings.burst = d.Get("burst").(int)
+
+	if v := d.Get("request_timeout").(string); v != "" {
+		timeout, err := time.ParseDuration(v)
+		if err != nil {
+			return fmt.Errorf("failed to parse request_timeout: %s", err)
+		}
+		settings.timeout = timeout
 	}
-	return clientset
+	if v := d.Get("proxy_url").(string); v != "" {
+		proxyURL, err := url.Parse(v)
+		if err != nil {
+			return fmt.Errorf("failed to parse proxy_url: %s", err)
+		}
+		settings.proxy = http.ProxyURL(proxyURL)
+	}
+
+	return nil
+}
+
+// expandHomeDir resolves a leading ~ in a kube config path the same way a shell would.
+func expandHomeDir(path string) string {
+	if path == "~" {
+		return homedir.HomeDir()
+	}
+	if strings.HasPrefix(path, "~/") {
+		return filepath.Join(homedir.HomeDir(), path[2:])
+	}
+	return path
+}
+
+// inClusterConfig authenticates with the pod's service account token, unless a credential
+// plugin was configured, in which case the plugin supplies the credentials instead. Outside
+// of a pod there's no cluster to connect to, which is an error.
+func inClusterConfig(exec *clientcmdapi.ExecConfig) (*rest.Config, error) {
+	host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+	if host == "" || port == "" {
+		return nil, fmt.Errorf("no cluster is configured: set `host`, `kubeconfig` or `config_path`, " +
+			"create ~/.kube/config, or run Terraform in a pod of the cluster")
+	}
+
+	config := &rest.Config{
+		Host:            "https://" + net.JoinHostPort(host, port),
+		BearerTokenFile: "/run/secrets/kubernetes.io/serviceaccount/token",
+		TLSClientConfig: rest.TLSClientConfig{
+			Insecure: false,
+			CAFile:   "/run/secrets/kubernetes.io/serviceaccount/ca.crt",
+		},
+	}
+	if exec != nil {
+		config.BearerTokenFile = ""
+		config.ExecProvider = exec
+	}
+	return config, nil
+}
+
+func execSchema() *schema.Resource {
+	return &schema.Resource{
+		Schema: map[string]*schema.Schema{
+			"api_version": {
+				Type:        schema.TypeString,
+				Required:    true,
+				Description: "The API version of the `ExecCredential` the plugin returns, e.g. `client.authentication.k8s.io/v1beta1`.",
+			},
+			"command": {
+				Type:        schema.TypeString,
+				Required:    true,
+				Description: "The command to execute.",
+			},
+			"args": {
+				Type:        schema.TypeList,
+				Optional:    true,
+				Elem:        &schema.Schema{Type: schema.TypeString},
+				Description: "Arguments to pass when executing the plugin.",
+			},
+			"env": {
+				Type:        schema.TypeMap,
+				Optional:    true,
+				Elem:        &schema.Schema{Type: schema.TypeString},
+				Description: "Environment variables to set when executing the plugin.",
+			},
+		},
+	}
+}
+
+// expandExecConfig turns an `exec` block into the credential plugin configuration client-go
+// uses to fetch, cache and refresh tokens. It returns nil when the block isn't set.
+func expandExecConfig(l []interface{}) *clientcmdapi.ExecConfig {
+	if len(l) == 0 || l[0] == nil {
+		return nil
+	}
+	in := l[0].(map[string]interface{})
+
+	exec := &clientcmdapi.ExecConfig{
+		APIVersion:      in["api_version"].(string),
+		Command:         in["command"].(string),
+		InteractiveMode: clientcmdapi.NeverExecInteractiveMode,
+	}
+	for _, arg := range in["args"].([]interface{}) {
+		exec.Args = append(exec.Args, arg.(string))
+	}
+	for name, value := range in["env"].(map[string]interface{}) {
+		exec.Env = append(exec.Env, clientcmdapi.ExecEnvVar{Name: name, Value: value.(string)})
+	}
+	sort.Slice(exec.Env, func(i, j int) bool { return exec.Env[i].Name < exec.Env[j].Name })
+
+	return exec
+}
+
+// clusterSchema lets a resource connect to a different cluster than the provider, e.g. one
+// created earlier in the same run.
+func clusterSchema() *schema.Resource {
+	return &schema.Resource{
+		Schema: map[string]*schema.Schema{
+			"host": {
+				Type:     schema.TypeString,
+				Required: true,
+				// The inventory doesn't tell clusters apart; moving an installation would leave
+				// its objects behind on the old one.
+				ForceNew:    true,
+				Description: "The hostname (in form of URI) of the Kubernetes API server. Changing it replaces the installation.",
+			},
+			"token": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Sensitive:   true,
+				Description: "Token used to authenticate to the Kubernetes API server.",
+			},
+			"cluster_ca_certificate": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Description: "PEM-encoded root certificates bundle for TLS authentication.",
+			},
+			"exec": {
+				Type:        schema.TypeList,
+				Optional:    true,
+				MaxItems:    1,
+				Description: "Run a credential plugin to obtain short-lived credentials for this cluster.",
+				Elem:        execSchema(),
+			},
+		},
+	}
+}
+
+func impersonateSchema() *schema.Resource {
+	return &schema.Resource{
+		Schema: map[string]*schema.Schema{
+			"user": {
+				Type:        schema.TypeString,
+				Required:    true,
+				Description: "The user to act as, e.g. `system:serviceaccount:tenant:deployer`.",
+			},
+			"uid": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Description: "The UID of the user to act as.",
+			},
+			"groups": {
+				Type:        schema.TypeList,
+				Optional:    true,
+				Elem:        &schema.Schema{Type: schema.TypeString},
+				Description: "The groups to act as.",
+			},
+			"extra": {
+				Type:        schema.TypeSet,
+				Optional:    true,
+				Description: "Extra fields to attach to the impersonated user.",
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"key": {
+							Type:        schema.TypeString,
+							Required:    true,
+							Description: "The name of the extra field, e.g. `scopes`.",
+						},
+						"values": {
+							Type:        schema.TypeList,
+							Required:    true,
+							Elem:        &schema.Schema{Type: schema.TypeString},
+							Description: "The values of the extra field.",
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+// expandImpersonationConfig turns an `impersonate` block into the identity client-go sends
+// in the Impersonate-* headers. It returns nil when the block isn't set.
+func expandImpersonationConfig(l []interface{}) *rest.ImpersonationConfig {
+	if len(l) == 0 || l[0] == nil {
+		return nil
+	}
+	in := l[0].(map[string]interface{})
+
+	impersonate := &rest.ImpersonationConfig{
+		UserName: in["user"].(string),
+		UID:      in["uid"].(string),
+	}
+	for _, group := range in["groups"].([]interface{}) {
+		impersonate.Groups = append(impersonate.Groups, group.(string))
+	}
+	for _, e := range in["extra"].(*schema.Set).List() {
+		extra := e.(map[string]interface{})
+		if impersonate.Extra == nil {
+			impersonate.Extra = map[string][]string{}
+		}
+		for _, value := range extra["values"].([]interface{}) {
+			impersonate.Extra[extra["key"].(string)] = append(impersonate.Extra[extra["key"].(string)], value.(string))
+		}
+	}
+
+	return impersonate
 }
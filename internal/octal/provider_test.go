@@ -1,9 +1,14 @@
 package octal
 
 import (
+	"context"
+	"net/http"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
 )
 
 // providerFactories are used to instantiate a octal during acceptance testing.
@@ -26,3 +31,381 @@ func testAccPreCheck(t *testing.T) {
 	// about the appropriate environment variables being set are common to see in a pre-check
 	// function.
 }
+
+const testKubeConfig = `
+apiVersion: v1
+kind: Config
+clusters:
+- name: one
+  cluster:
+    server: https://one.example.com
+- name: two
+  cluster:
+    server: https://two.example.com
+users:
+- name: admin
+  user:
+    token: secret
+contexts:
+- name: one
+  context:
+    cluster: one
+    user: admin
+- name: two
+  context:
+    cluster: two
+    user: admin
+current-context: one
+`
+
+func TestGetKubeConfig(t *testing.T) {
+	cases := map[string]struct {
+		raw  map[string]interface{}
+		host string
+	}{
+		"current context": {
+			raw:  map[string]interface{}{"kubeconfig": testKubeConfig},
+			host: "https://one.example.com",
+		},
+		"config_context": {
+			raw:  map[string]interface{}{"kubeconfig": testKubeConfig, "config_context": "two"},
+			host: "https://two.example.com",
+		},
+		"config_context_cluster": {
+			raw:  map[string]interface{}{"kubeconfig": testKubeConfig, "config_context_cluster": "two"},
+			host: "https://two.example.com",
+		},
+		"host": {
+			raw:  map[string]interface{}{"host": "three.example.com", "token": "secret", "insecure": true},
+			host: "https://three.example.com",
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			d := schema.TestResourceDataRaw(t, New("dev")().Schema, tc.raw)
+			config, err := getKubeConfig(d)
+			if err != nil {
+				t.Fatalf("err: %s", err)
+			}
+			if config.Host != tc.host {
+				t.Fatalf("expected host %q, got %q", tc.host, config.Host)
+			}
+			if config.BearerToken != "secret" {
+				t.Fatalf("expected bearer token to be set")
+			}
+		})
+	}
+}
+
+func TestGetKubeConfigExec(t *testing.T) {
+	d := schema.TestResourceDataRaw(t, New("dev")().Schema, map[string]interface{}{
+		"host": "https://one.example.com",
+		"exec": []interface{}{
+			map[string]interface{}{
+				"api_version": "client.authentication.k8s.io/v1beta1",
+				"command":     "aws-iam-authenticator",
+				"args":        []interface{}{"token", "-i", "example"},
+				"env":         map[string]interface{}{"AWS_PROFILE": "ci"},
+			},
+		},
+	})
+
+	config, err := getKubeConfig(d)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if config.ExecProvider == nil {
+		t.Fatalf("expected an exec provider to be configured")
+	}
+	if config.ExecProvider.Command != "aws-iam-authenticator" || len(config.ExecProvider.Args) != 3 {
+		t.Fatalf("unexpected exec provider: %#v", config.ExecProvider)
+	}
+	if len(config.ExecProvider.Env) != 1 || config.ExecProvider.Env[0].Value != "ci" {
+		t.Fatalf("unexpected exec provider env: %#v", config.ExecProvider.Env)
+	}
+}
+
+func TestProviderConfigureInvalidKubeConfig(t *testing.T) {
+	p := New("dev")()
+	diags := p.Configure(context.Background(), terraform.NewResourceConfigRaw(map[string]interface{}{
+		"kubeconfig": "clusters: [",
+	}))
+	if !diags.HasError() {
+		t.Fatalf("expected a malformed kubeconfig to produce an error diagnostic")
+	}
+}
+
+func TestProviderConfigureUnknownConnection(t *testing.T) {
+	for name, config := range map[string]map[string]interface{}{
+		"host": {"host": unknownValue, "token": "secret"},
+		"exec": {
+			"host": "https://one.example.com",
+			"exec": []interface{}{
+				map[string]interface{}{"api_version": "client.authentication.k8s.io/v1beta1", "command": unknownValue},
+			},
+		},
+	} {
+		p := New("dev")()
+		if diags := p.Configure(context.Background(), terraform.NewResourceConfigRaw(config)); diags.HasError() {
+			t.Fatalf("%s: unexpected diagnostics: %#v", name, diags)
+		}
+		if _, err := p.Meta().(*apiClient).DynamicClient(); err != errConfigUnknown {
+			t.Errorf("%s: expected the client to wait for the connection, got %v", name, err)
+		}
+	}
+
+	// Once it's known, the same provider connects.
+	p := New("dev")()
+	p.Configure(context.Background(), terraform.NewResourceConfigRaw(map[string]interface{}{"host": unknownValue}))
+	diags := p.Configure(context.Background(), terraform.NewResourceConfigRaw(map[string]interface{}{
+		"host":  "https://one.example.com",
+		"token": "secret",
+	}))
+	if diags.HasError() {
+		t.Fatalf("unexpected diagnostics: %#v", diags)
+	}
+	if config := p.Meta().(*apiClient).config; config == nil || config.Host != "https://one.example.com" {
+		t.Fatalf("expected the known host to be used, got %#v", config)
+	}
+}
+
+func TestProviderConfigureNoCluster(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	for _, name := range []string{"KUBE_HOST", "KUBE_CONFIG_PATHS", "KUBERNETES_SERVICE_HOST", "KUBERNETES_SERVICE_PORT"} {
+		t.Setenv(name, "")
+	}
+
+	p := New("dev")()
+	diags := p.Configure(context.Background(), terraform.NewResourceConfigRaw(map[string]interface{}{}))
+	if !diags.HasError() || !strings.Contains(diags[0].Detail, "no cluster is configured") {
+		t.Fatalf("expected an error when there's no cluster to connect to, got %#v", diags)
+	}
+
+	t.Setenv("KUBERNETES_SERVICE_HOST", "10.96.0.1")
+	t.Setenv("KUBERNETES_SERVICE_PORT", "443")
+	p = New("dev")()
+	if diags := p.Configure(context.Background(), terraform.NewResourceConfigRaw(map[string]interface{}{})); diags.HasError() {
+		t.Fatalf("unexpected diagnostics: %#v", diags)
+	}
+	if host := p.Meta().(*apiClient).config.Host; host != "https://10.96.0.1:443" {
+		t.Fatalf("expected the in-cluster host, got %q", host)
+	}
+}
+
+func TestApiClientUnknownConfig(t *testing.T) {
+	if _, err := (&apiClient{}).DynamicClient(); err != errConfigUnknown {
+		t.Fatalf("expected errConfigUnknown, got %v", err)
+	}
+}
+
+func TestProviderConfigureImpersonation(t *testing.T) {
+	p := New("dev")()
+	diags := p.Configure(context.Background(), terraform.NewResourceConfigRaw(map[string]interface{}{
+		"host":  "https://one.example.com",
+		"token": "secret",
+		"impersonate": []interface{}{
+			map[string]interface{}{
+				"user":   "system:serviceaccount:tenant:deployer",
+				"groups": []interface{}{"tenants"},
+				"extra": []interface{}{
+					map[string]interface{}{"key": "scopes", "values": []interface{}{"a", "b"}},
+				},
+			},
+		},
+	}))
+	if diags.HasError() {
+		t.Fatalf("unexpected diagnostics: %#v", diags)
+	}
+
+	impersonate := p.Meta().(*apiClient).config.Impersonate
+	if impersonate.UserName != "system:serviceaccount:tenant:deployer" {
+		t.Fatalf("unexpected impersonated user: %q", impersonate.UserName)
+	}
+	if len(impersonate.Groups) != 1 || len(impersonate.Extra["scopes"]) != 2 {
+		t.Fatalf("unexpected impersonation config: %#v", impersonate)
+	}
+
+	d := schema.TestResourceDataRaw(t, resourceOctalCertManager().Schema, map[string]interface{}{
+		"impersonate": []interface{}{
+			map[string]interface{}{"user": "admin"},
+		},
+	})
+	client, err := resourceClient(d, p.Meta())
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if client.config.Impersonate.UserName != "admin" || len(client.config.Impersonate.Groups) != 0 {
+		t.Fatalf("expected the resource to override the provider's impersonation, got %#v", client.config.Impersonate)
+	}
+	if p.Meta().(*apiClient).config.Impersonate.UserName == "admin" {
+		t.Fatalf("the resource override must not leak into the provider's client")
+	}
+
+	d = schema.TestResourceDataRaw(t, resourceOctalCertManager().Schema, map[string]interface{}{
+		"cluster": []interface{}{
+			map[string]interface{}{"host": "https://two.example.com", "token": "other"},
+		},
+	})
+	client, err = resourceClient(d, p.Meta())
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if client.config.Impersonate.UserName != "" || len(client.config.Impersonate.Groups) != 0 {
+		t.Fatalf("expected another cluster not to inherit the provider's impersonation, got %#v", client.config.Impersonate)
+	}
+}
+
+func TestResourceClusterForceNew(t *testing.T) {
+	resource := resourceOctalCertManager()
+	// Rendering isn't what's under test, and would reach for the clusters.
+	resource.CustomizeDiff = nil
+
+	one := map[string]interface{}{
+		"namespace": "cert-manager",
+		"cluster":   []interface{}{map[string]interface{}{"host": "https://one.example.com"}},
+	}
+	state := schema.TestResourceDataRaw(t, resource.Schema, one)
+	state.SetId("instance")
+
+	for name, config := range map[string]map[string]interface{}{
+		"another host": {
+			"namespace": "cert-manager",
+			"cluster":   []interface{}{map[string]interface{}{"host": "https://two.example.com"}},
+		},
+		"the provider's cluster": {"namespace": "cert-manager"},
+	} {
+		diff, err := resource.Diff(context.Background(), state.State(), terraform.NewResourceConfigRaw(config), nil)
+		if err != nil {
+			t.Fatalf("%s: %s", name, err)
+		}
+		if !diff.RequiresNew() {
+			t.Errorf("%s: expected moving the installation to replace it", name)
+		}
+	}
+
+	diff, err := resource.Diff(context.Background(), state.State(), terraform.NewResourceConfigRaw(one), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff.RequiresNew() {
+		t.Errorf("expected the same cluster to keep the installation, got %#v", diff)
+	}
+}
+
+func TestProviderConfigureClientTuning(t *testing.T) {
+	p := New("1.2.3")()
+	diags := p.Configure(context.Background(), terraform.NewResourceConfigRaw(map[string]interface{}{
+		"host":            "https://one.example.com",
+		"token":           "secret",
+		"qps":             25.5,
+		"burst":           40,
+		"request_timeout": "45s",
+		"proxy_url":       "socks5://proxy.example.com:1080",
+		"tls_server_name": "kubernetes.default",
+	}))
+	if diags.HasError() {
+		t.Fatalf("unexpected diagnostics: %#v", diags)
+	}
+
+	config := p.Meta().(*apiClient).config
+	if config.QPS != 25.5 || config.Burst != 40 {
+		t.Fatalf("unexpected rate limits: qps=%v burst=%v", config.QPS, config.Burst)
+	}
+	if config.Timeout != 45*time.Second {
+		t.Fatalf("unexpected timeout: %s", config.Timeout)
+	}
+	if config.TLSClientConfig.ServerName != "kubernetes.default" {
+		t.Fatalf("unexpected TLS server name: %q", config.TLSClientConfig.ServerName)
+	}
+	proxyURL, err := config.Proxy(&http.Request{})
+	if err != nil || proxyURL.String() != "socks5://proxy.example.com:1080" {
+		t.Fatalf("unexpected proxy: %v (%v)", proxyURL, err)
+	}
+	if !strings.Contains(config.UserAgent, "terraform-provider-octal/1.2.3") {
+		t.Fatalf("expected the user agent to carry the provider version, got %q", config.UserAgent)
+	}
+}
+
+func TestResourceClientClusterOverride(t *testing.T) {
+	p := New("dev")()
+	diags := p.Configure(context.Background(), terraform.NewResourceConfigRaw(map[string]interface{}{
+		"host":  "https://one.example.com",
+		"token": "secret",
+		"qps":   20.0,
+	}))
+	if diags.HasError() {
+		t.Fatalf("unexpected diagnostics: %#v", diags)
+	}
+
+	cluster := func(host string) *schema.ResourceData {
+		return schema.TestResourceDataRaw(t, resourceOctalCertManager().Schema, map[string]interface{}{
+			"cluster": []interface{}{
+				map[string]interface{}{
+					"host": host,
+					"exec": []interface{}{
+						map[string]interface{}{
+							"api_version": "client.authentication.k8s.io/v1beta1",
+							"command":     "gke-gcloud-auth-plugin",
+						},
+					},
+				},
+			},
+		})
+	}
+
+	first, err := resourceClient(cluster("two.example.com"), p.Meta())
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if first.config.Host != "https://two.example.com" || first.config.ExecProvider == nil {
+		t.Fatalf("expected the resource to connect to its own cluster, got %#v", first.config)
+	}
+	if first.config.QPS != 20 {
+		t.Fatalf("expected the provider's client settings to carry over, got qps=%v", first.config.QPS)
+	}
+
+	second, err := resourceClient(cluster("two.example.com"), p.Meta())
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if first != second {
+		t.Fatalf("expected resources with the same connection to share a client")
+	}
+
+	third, err := resourceClient(cluster("three.example.com"), p.Meta())
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if first == third {
+		t.Fatalf("expected resources with different connections to get different clients")
+	}
+}
+
+func TestResourceClientTuningWithUnknownProvider(t *testing.T) {
+	p := New("dev")()
+	diags := p.Configure(context.Background(), terraform.NewResourceConfigRaw(map[string]interface{}{
+		"host":            unknownValue,
+		"qps":             20.0,
+		"burst":           30,
+		"request_timeout": "45s",
+		"proxy_url":       "http://proxy.example.com:3128",
+	}))
+	if diags.HasError() {
+		t.Fatalf("unexpected diagnostics: %#v", diags)
+	}
+
+	d := schema.TestResourceDataRaw(t, resourceOctalCertManager().Schema, map[string]interface{}{
+		"cluster": []interface{}{
+			map[string]interface{}{"host": "https://two.example.com", "token": "other"},
+		},
+	})
+	client, err := resourceClient(d, p.Meta())
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	config := client.config
+	if config.QPS != 20 || config.Burst != 30 || config.Timeout != 45*time.Second || config.Proxy == nil {
+		t.Fatalf("expected the provider's client settings to carry over, got %#v", config)
+	}
+}
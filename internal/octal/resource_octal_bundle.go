@@ -0,0 +1,365 @@
+package octal
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	octal_schema "github.com/dylanturn/terraform-provider-octal/internal/schema"
+	"github.com/hashicorp/go-cty/cty"
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/customdiff"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/resource"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+)
+
+// resourceBundle builds the resource that installs a bundle: its schema, from the bundle's
+// components and parameters, and the CRUD functions shared by every bundle.
+func resourceBundle(bundle *bundleDefinition) *schema.Resource {
+	return &schema.Resource{
+		Description:   bundle.Description,
+		CreateContext: resourceBundleCreate(bundle),
+		ReadContext:   resourceBundleRead(bundle),
+		UpdateContext: resourceBundleUpdate(bundle),
+		DeleteContext: resourceBundleDelete(bundle),
+		Importer: &schema.ResourceImporter{
+			StateContext: resourceBundleImport(bundle),
+		},
+		CustomizeDiff: customdiff.All(
+			customizeDiffRender(bundle),
+			customizeDiffPrune(bundle),
+			customizeDiffDrift,
+		),
+		Schema: bundleSchema(bundle),
+		Timeouts: &schema.ResourceTimeout{
+			Create: schema.DefaultTimeout(10 * time.Minute),
+			Update: schema.DefaultTimeout(10 * time.Minute),
+			Delete: schema.DefaultTimeout(5 * time.Minute),
+		},
+	}
+}
+
+func bundleSchema(bundle *bundleDefinition) map[string]*schema.Schema {
+	resourceSchema := map[string]*schema.Schema{
+		"name": {
+			Type:         schema.TypeString,
+			ForceNew:     true,
+			Optional:     true,
+			Default:      bundle.Name,
+			Description:  "A name that will be given to the deployment",
+			ValidateFunc: validateName,
+		},
+		"version": {
+			Type:     schema.TypeString,
+			Optional: true,
+			Description: fmt.Sprintf("The version of %s to install. One of: `%s`. A constraint such as `~> 1.8` installs the newest of them that satisfies it",
+				bundle.Name, strings.Join(bundle.versions(), "`, `")),
+			Default:      bundle.DefaultVersion,
+			ValidateFunc: validateBundleVersion(bundle),
+		},
+		"namespace": {
+			Type:        schema.TypeString,
+			Required:    true,
+			Description: "The namespace to deploy Project-Octal in",
+		},
+		"cluster": {
+			Type:        schema.TypeList,
+			Optional:    true,
+			MaxItems:    1,
+			Description: "Connect to this cluster instead of the one configured on the provider. The provider's `impersonate` block doesn't apply to it, only this resource's own",
+			Elem:        clusterSchema(),
+		},
+		"impersonate": {
+			Type:        schema.TypeList,
+			Optional:    true,
+			MaxItems:    1,
+			Description: "Impersonate another identity for this installation, overriding the provider's `impersonate` block",
+			Elem:        impersonateSchema(),
+		},
+		"adopt_existing": {
+			Type:        schema.TypeBool,
+			Optional:    true,
+			Default:     false,
+			Description: "Take over an installation made with Helm or kubectl when creating the resource. The objects of the bundle that exist already must have been installed at `version`; they're labelled as this installation's and managed in place. Only objects with the names this bundle gives them are adopted: when others look like part of the installation, such as the differently named RBAC of the upstream Helm chart, nothing is installed and they're listed in an error",
+		},
+		"helm_release_secrets": {
+			Type:         schema.TypeString,
+			Optional:     true,
+			Default:      helmReleaseSecretsKeep,
+			Description:  "What to do with the Helm release of an adopted installation. `keep`: Leave the release alone. | `delete`: Delete the release's secrets and Helm's annotations, so Helm forgets about it",
+			ValidateFunc: validation.StringInSlice([]string{helmReleaseSecretsKeep, helmReleaseSecretsDelete}, false),
+		},
+		"on_failure": {
+			Type:         schema.TypeString,
+			Optional:     true,
+			Default:      onFailureKeep,
+			Description:  "What to do with the objects a failed create applied. `keep`: Keep them and let Terraform taint the resource, so the next apply replaces it. | `rollback`: Delete them, leaving nothing behind",
+			ValidateFunc: validation.StringInSlice([]string{onFailureKeep, onFailureRollback}, false),
+		},
+		"prune": {
+			Type:        schema.TypeBool,
+			Optional:    true,
+			Default:     true,
+			Description: "Delete objects that were removed from the bundle, e.g. by a new `version`, when updating. Turned off, they stay on the cluster until the resource is destroyed",
+		},
+		"pruned_objects": {
+			Type:        schema.TypeList,
+			Computed:    true,
+			Description: "The objects the latest update deleted because the bundle no longer contains them. In a plan, the objects the update is going to delete: review them there, as nothing else announces them before they're deleted",
+			Elem:        &schema.Schema{Type: schema.TypeString},
+		},
+		"drift_mode": {
+			Type:         schema.TypeString,
+			Optional:     true,
+			Default:      driftModeCorrect,
+			Description:  "What to do when objects on the cluster no longer match the bundle, e.g. after a `kubectl edit`. `correct`: Plan an update that applies the bundle again. | `warn`: Only warn when refreshing. | `ignore`: Don't check for drift",
+			ValidateFunc: validation.StringInSlice([]string{driftModeCorrect, driftModeWarn, driftModeIgnore}, false),
+		},
+		"drift": {
+			Type:        schema.TypeList,
+			Computed:    true,
+			Description: "The fields of the installed objects that no longer match the bundle, as of the last refresh",
+			Elem:        &schema.Schema{Type: schema.TypeString},
+		},
+		"rendered_hash": {
+			Type:        schema.TypeString,
+			Computed:    true,
+			Description: "A hash of the rendered bundle. It changes whenever the objects that would be applied change",
+		},
+		"changes": {
+			Type:        schema.TypeList,
+			Computed:    true,
+			Description: "The objects the latest apply created, changed or deleted, with the fields the bundle changed. In a plan, the changes the apply is going to make. Fields changed on the cluster are listed in `drift` instead",
+			Elem:        &schema.Schema{Type: schema.TypeString},
+		},
+		"inventory": {
+			Type:        schema.TypeList,
+			Computed:    true,
+			Description: "Every Kubernetes object this installation applied",
+			Elem:        octal_schema.InventoryItem(),
+		},
+		"custom_resources": {
+			Type:        schema.TypeList,
+			Optional:    false,
+			Computed:    true,
+			Description: "Additional annotations to add to the deployment",
+			Elem:        octal_schema.CustomResourceDefinition(),
+		},
+	}
+
+	for _, component := range bundle.Components {
+		resourceSchema[component.Component.GetName()] = &schema.Schema{
+			Type:        schema.TypeList,
+			MaxItems:    1,
+			Required:    true,
+			Description: component.Description,
+			Elem:        component.Schema(),
+		}
+	}
+
+	for _, parameter := range bundle.Parameters {
+		resourceSchema[parameter.Name] = &schema.Schema{
+			Type:        parameter.Type,
+			Optional:    true,
+			Default:     parameter.Default,
+			Description: parameter.Description,
+		}
+	}
+
+	return resourceSchema
+}
+
+func resourceBundleCreate(bundle *bundleDefinition) schema.CreateContextFunc {
+	return func(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+		meta, err := resourceClient(d, meta)
+		if err != nil {
+			return diag.FromErr(err)
+		}
+		var diags = diag.Diagnostics{}
+
+		// The id names the installation in its objects, so it's set before they're rendered.
+		// Until something is applied, failing clears it again, so nothing is left in state.
+		d.SetId(resource.UniqueId())
+
+		objects, err := bundle.objects(ctx, d, meta)
+		if err != nil {
+			d.SetId("")
+			return diag.FromErr(err)
+		}
+
+		var adopted adoption
+		if d.Get("adopt_existing").(bool) {
+			var adoptDiags diag.Diagnostics
+			adopted, adoptDiags = adoptObjects(ctx, d, meta, objects)
+			if adoptDiags.HasError() {
+				d.SetId("")
+				return adoptDiags
+			}
+			diags = append(diags, adoptDiags...)
+		}
+
+		inventory, applyDiags := applyObjects(ctx, d, meta, objects)
+		diags = append(diags, applyDiags...)
+		d.Set("inventory", flattenInventory(inventory))
+		if !diags.HasError() {
+			diags = append(diags, waitForReady(ctx, meta, objects, d.Timeout(schema.TimeoutCreate))...)
+		}
+		if !diags.HasError() {
+			diags = append(diags, releaseHelm(ctx, d, meta, adopted)...)
+		}
+
+		if diags.HasError() && d.Get("on_failure").(string) == onFailureRollback {
+			rollbackCtx, cancel := rollbackContext(ctx, d.Timeout(schema.TimeoutDelete))
+			remaining, rollbackDiags := rollbackObjects(rollbackCtx, meta, inventory, objects)
+			cancel()
+			diags = append(diags, rollbackDiags...)
+			if len(remaining) == 0 {
+				d.SetId("")
+				return diags
+			}
+			d.Set("inventory", flattenInventory(remaining))
+		}
+
+		hash, err := renderedHash(ctx, bundle, d, meta)
+		if err != nil {
+			diags = append(diags, diag.FromErr(err)...)
+		}
+		d.Set("rendered_hash", hash)
+
+		diags = append(diags, resourceBundleRead(bundle)(ctx, d, meta)...)
+
+		return diags
+	}
+}
+
+func resourceBundleRead(bundle *bundleDefinition) schema.ReadContextFunc {
+	return func(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+		meta, err := resourceClient(d, meta)
+		if err != nil {
+			return diag.FromErr(err)
+		}
+		var diags diag.Diagnostics
+
+		inventory := expandInventory(d.Get("inventory").([]interface{}))
+		if len(inventory) == 0 {
+			// Installations created before the inventory was recorded in state are found from
+			// the bundle instead.
+			objects, err := renderBundle(ctx, bundle, d, meta)
+			if err != nil {
+				diags = append(diags, renderDiagnostic(bundle, err))
+			} else {
+				var discoverDiags diag.Diagnostics
+				inventory, discoverDiags = discoverInventory(ctx, meta, objects)
+				diags = append(diags, discoverDiags...)
+			}
+		}
+
+		inventory, live, readDiags := readInventory(ctx, meta, inventory)
+		diags = append(diags, readDiags...)
+		// An inventory that couldn't be read in full may only look empty.
+		if len(inventory) == 0 && !d.IsNewResource() && !diags.HasError() {
+			tflog.Warn(ctx, fmt.Sprintf("None of the objects of %s exist anymore, removing it from state", d.Id()))
+			d.SetId("")
+			return diags
+		}
+		d.Set("inventory", flattenInventory(inventory))
+
+		var drift []string
+		if d.Get("drift_mode").(string) != driftModeIgnore {
+			var driftDiags diag.Diagnostics
+			drift, driftDiags = readDrift(ctx, bundle, d, meta, live)
+			if driftDiags.HasError() {
+				// The drift found by the last refresh stands until it can be checked again.
+				drift = expandStringList(d.Get("drift").([]interface{}))
+			}
+			diags = append(diags, driftDiags...)
+		}
+		d.Set("drift", drift)
+		if len(drift) > 0 && d.Get("drift_mode").(string) == driftModeWarn {
+			diags = append(diags, diag.Diagnostic{
+				Severity: diag.Warning,
+				Summary:  fmt.Sprintf("The objects of %s drifted from the bundle", d.Get("name")),
+				Detail: fmt.Sprintf("These fields were changed outside of Terraform:\n\n  - %s\n\n"+
+					"Set `drift_mode = \"correct\"` to have Terraform apply the bundle again.", strings.Join(drift, "\n  - ")),
+				AttributePath: cty.GetAttrPath("drift_mode"),
+			})
+		}
+
+		for _, object := range live {
+			// The component blocks describe each component's Deployment.
+			if object.GetKind() == "Deployment" {
+				component := object.GetLabels()["app.kubernetes.io/component"]
+				d.Set(component, flattenMetadata(component, object))
+			}
+		}
+
+		return diags
+	}
+}
+
+func resourceBundleUpdate(bundle *bundleDefinition) schema.UpdateContextFunc {
+	return func(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+		meta, err := resourceClient(d, meta)
+		if err != nil {
+			return diag.FromErr(err)
+		}
+		var diags diag.Diagnostics
+
+		previous := expandInventory(d.Get("inventory").([]interface{}))
+
+		objects, err := bundle.objects(ctx, d, meta)
+		if err != nil {
+			return diag.FromErr(err)
+		}
+		inventory, applyDiags := applyObjects(ctx, d, meta, objects)
+		diags = append(diags, applyDiags...)
+		if !diags.HasError() {
+			diags = append(diags, waitForReady(ctx, meta, objects, d.Timeout(schema.TimeoutUpdate))...)
+		}
+
+		// Objects the bundle no longer contains are only pruned once the new ones are up. Until
+		// then, or when pruning is turned off, they stay in the inventory so destroy removes them.
+		leftovers := inventoryDifference(previous, inventory)
+		if len(leftovers) > 0 {
+			if diags.HasError() || !d.Get("prune").(bool) {
+				inventory = append(inventory, leftovers...)
+			} else {
+				pruneDiags := deleteObjects(ctx, meta, inventoryObjects(leftovers))
+				diags = append(diags, pruneDiags...)
+				if pruneDiags.HasError() {
+					inventory = append(inventory, leftovers...)
+				}
+				d.Set("pruned_objects", describeInventory(leftovers))
+			}
+		}
+		d.Set("inventory", flattenInventory(inventory))
+
+		hash, err := renderedHash(ctx, bundle, d, meta)
+		if err != nil {
+			diags = append(diags, diag.FromErr(err)...)
+		}
+		d.Set("rendered_hash", hash)
+
+		diags = append(diags, resourceBundleRead(bundle)(ctx, d, meta)...)
+
+		return diags
+	}
+}
+
+func resourceBundleDelete(bundle *bundleDefinition) schema.DeleteContextFunc {
+	return func(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+		meta, err := resourceClient(d, meta)
+		if err != nil {
+			return diag.FromErr(err)
+		}
+		var diags diag.Diagnostics
+
+		inventory := expandInventory(d.Get("inventory").([]interface{}))
+		diags = append(diags, deleteObjects(ctx, meta, inventoryObjects(inventory))...)
+
+		return diags
+	}
+}
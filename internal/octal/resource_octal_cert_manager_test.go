@@ -1,9 +1,15 @@
 package octal
 
 import (
+	"context"
+	"strings"
 	"testing"
 
+	resource_component "github.com/dylanturn/terraform-provider-octal/internal/component"
+	"github.com/dylanturn/terraform-provider-octal/internal/util"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/resource"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 )
 
 func TestAccResourceScaffolding(t *testing.T) {
@@ -26,3 +32,70 @@ resource "octal_cert_manager" "foo" {
   
 }
 `
+
+func TestCertManagerObjects(t *testing.T) {
+	d := schema.TestResourceDataRaw(t, resourceOctalCertManager().Schema, map[string]interface{}{
+		"namespace": "cert-manager",
+	})
+	objects, err := certManagerBundle().objects(context.Background(), d, newTestApiClient())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	components := map[string]int{}
+	for _, object := range objects {
+		components[object.component]++
+		if version := object.object.GetLabels()["app.kubernetes.io/version"]; object.component != "namespace" && version != "1.8.2" {
+			t.Errorf("expected %s %s to be labelled with the version, got %q", object.object.GetKind(), object.object.GetName(), version)
+		}
+		subjects, _, _ := unstructured.NestedSlice(object.object.Object, "subjects")
+		for _, subject := range subjects {
+			if namespace, ok := subject.(map[string]interface{})["namespace"]; ok && namespace != "cert-manager" && namespace != "kube-system" {
+				t.Errorf("expected the subjects of %s to be in the namespace of the installation, got %v", object.object.GetName(), namespace)
+			}
+		}
+		if object.object.GetKind() == "" || object.object.GetName() == "" {
+			t.Errorf("expected every object to have a kind and a name, got %#v", object.object.Object)
+		}
+	}
+	for _, component := range []string{"namespace", "controller", "cainjector", "webhook"} {
+		if components[component] == 0 {
+			t.Errorf("expected objects of the %s component, got %v", component, components)
+		}
+	}
+}
+
+func TestComponentGetObjects(t *testing.T) {
+	component := resource_component.ResourceComponent{
+		Name: "controller",
+		Manifests: map[string][]util.Manifest{
+			"1.8.2": testManifests(
+				"apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: settings\n",
+				"",
+				"apiVersion: policy/v1\nkind: PodDisruptionBudget\nmetadata:\n  name: controller\n",
+			),
+		},
+	}
+	objects, err := component.GetObjects(context.Background(), "1.8.2", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(objects) != 2 || objects[0].GetKind() != "ConfigMap" || objects[1].GetKind() != "PodDisruptionBudget" {
+		t.Errorf("expected the ConfigMap and the PodDisruptionBudget, got %#v", objects)
+	}
+
+	if _, err := component.GetObjects(context.Background(), "1.9.1", nil); err == nil {
+		t.Error("expected an error for a version the component doesn't ship")
+	}
+
+	component.Manifests["1.8.2"] = testManifests("kind: [ConfigMap", "metadata:\n  name: {{ .Name }}\n", "kind: ConfigMap\n")
+	_, err = component.GetObjects(context.Background(), "1.8.2", resource_component.Values{})
+	if err == nil {
+		t.Fatal("expected an error for manifests that don't render or decode")
+	}
+	for _, path := range []string{"manifest-0.yml", "manifest-1.yml", "manifest-2.yml"} {
+		if !strings.Contains(err.Error(), path) {
+			t.Errorf("expected the error to report %s, got %s", path, err)
+		}
+	}
+}
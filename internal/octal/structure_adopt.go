@@ -0,0 +1,286 @@
+package octal
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/go-cty/cty"
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	k8sschema "k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+const (
+	// helmReleaseSecretsKeep leaves the Helm release of an adopted installation alone.
+	helmReleaseSecretsKeep = "keep"
+	// helmReleaseSecretsDelete deletes the release's secrets, so Helm forgets about it.
+	helmReleaseSecretsDelete = "delete"
+
+	helmReleaseNameAnnotation      = "meta.helm.sh/release-name"
+	helmReleaseNamespaceAnnotation = "meta.helm.sh/release-namespace"
+)
+
+var secretKind = k8sschema.GroupVersionKind{Version: "v1", Kind: "Secret"}
+
+// helmRelease identifies the Helm release that installed an adopted object.
+type helmRelease struct {
+	Name      string
+	Namespace string
+}
+
+// adoption records what adoptObjects took over.
+type adoption struct {
+	releases []helmRelease
+	// helmObjects are the adopted objects that carry Helm's release annotations.
+	helmObjects []*unstructured.Unstructured
+}
+
+// objectVersion returns the cert-manager version an object was installed at, as told by
+// its labels, without the leading "v". Both Helm and the static manifests set the version
+// label; older charts only set the chart label.
+func objectVersion(object *unstructured.Unstructured) string {
+	labels := object.GetLabels()
+	if version := labels["app.kubernetes.io/version"]; version != "" {
+		return strings.TrimPrefix(version, "v")
+	}
+	if chart := labels["helm.sh/chart"]; chart != "" {
+		if index := strings.LastIndex(chart, "-"); index >= 0 {
+			return strings.TrimPrefix(chart[index+1:], "v")
+		}
+	}
+	return ""
+}
+
+// adoptObjects looks up the bundle's objects on the cluster, before anything is applied.
+// Those that exist already are marked as adopted, so applying takes them over in place,
+// but only if every one of them was installed at the requested version and no part of the
+// existing installation would be left running next to the bundle.
+func adoptObjects(ctx context.Context, d resourceSettings, meta interface{}, objects []bundleObject) (adoption, diag.Diagnostics) {
+	var adopted adoption
+	var diags diag.Diagnostics
+
+	if err := prepareBundle(ctx, d, meta, objects); err != nil {
+		return adopted, diag.FromErr(err)
+	}
+
+	var mismatched []string
+	releases := map[helmRelease]bool{}
+	for index := range objects {
+		object := objects[index].object
+
+		live, err := getObject(ctx, meta, object)
+		if apierrors.IsNotFound(err) || isNoMatch(err) {
+			continue
+		}
+		if err != nil {
+			diags = append(diags, diag.Diagnostic{
+				Severity:      diag.Error,
+				Summary:       fmt.Sprintf("Failed to look up %s %s to adopt it", object.GetKind(), objectName(object)),
+				Detail:        errorDetail(err),
+				AttributePath: componentPath(objects[index].component),
+			})
+			continue
+		}
+
+		if liveVersion := objectVersion(live); liveVersion != "" && liveVersion != objects[index].version {
+			mismatched = append(mismatched, fmt.Sprintf("  - %s %s: %s", object.GetKind(), objectName(object), liveVersion))
+			continue
+		}
+
+		objects[index].adopted = true
+		tflog.Info(ctx, fmt.Sprintf("Adopting %s %s", object.GetKind(), objectName(object)))
+
+		annotations := live.GetAnnotations()
+		if name := annotations[helmReleaseNameAnnotation]; name != "" {
+			release := helmRelease{Name: name, Namespace: annotations[helmReleaseNamespaceAnnotation]}
+			if !releases[release] {
+				releases[release] = true
+				adopted.releases = append(adopted.releases, release)
+			}
+			adopted.helmObjects = append(adopted.helmObjects, object)
+		}
+	}
+
+	if len(mismatched) > 0 {
+		diags = append(diags, diag.Diagnostic{
+			Severity: diag.Error,
+			Summary:  fmt.Sprintf("The existing installation doesn't match version %s", objects[0].version),
+			Detail: fmt.Sprintf("These objects were installed at another version:\n\n%s\n\n"+
+				"Set `version` to the installed version to adopt them, then upgrade.", strings.Join(mismatched, "\n")),
+		})
+	}
+
+	lookalikes, lookupDiags := findLookalikes(ctx, meta, objects)
+	diags = append(diags, lookupDiags...)
+	if len(lookalikes) > 0 {
+		diags = append(diags, diag.Diagnostic{
+			Severity: diag.Error,
+			Summary:  "Some objects of the existing installation can't be adopted",
+			Detail: fmt.Sprintf("Only objects with the names this bundle gives them are adopted. These look like part of "+
+				"the existing installation, but have other names, so the bundle would be installed next to them:\n\n%s\n\n"+
+				"Uninstall the existing installation instead of adopting it, or delete these objects first.", strings.Join(lookalikes, "\n")),
+			AttributePath: cty.GetAttrPath("adopt_existing"),
+		})
+	}
+
+	sort.Slice(adopted.releases, func(i, j int) bool {
+		return adopted.releases[i].Namespace+"/"+adopted.releases[i].Name < adopted.releases[j].Namespace+"/"+adopted.releases[j].Name
+	})
+	return adopted, diags
+}
+
+// findLookalikes looks for objects of the bundle's kinds that carry the component label of
+// one of its components, as the upstream Helm chart sets it, but weren't installed by this
+// provider and don't have the name of any of the bundle's objects. The chart names most of
+// its objects differently, so they can't be adopted.
+func findLookalikes(ctx context.Context, meta interface{}, objects []bundleObject) ([]string, diag.Diagnostics) {
+	var lookalikes []string
+	var diags diag.Diagnostics
+	if len(objects) == 0 || objects[0].bundle == nil {
+		return lookalikes, diags
+	}
+
+	var components []string
+	for _, component := range objects[0].bundle.Components {
+		components = append(components, component.Component.GetName())
+	}
+	options := metav1.ListOptions{
+		LabelSelector: fmt.Sprintf("app.kubernetes.io/component in (%s),app.kubernetes.io/managed-by!=terraform", strings.Join(components, ",")),
+	}
+
+	type scope struct {
+		gvk       k8sschema.GroupVersionKind
+		namespace string
+	}
+	var scopes []scope
+	seen := map[scope]bool{}
+	names := map[string]bool{}
+	for _, object := range objects {
+		names[newInventoryItem(object.object).key()] = true
+		key := scope{object.object.GroupVersionKind(), object.object.GetNamespace()}
+		if !seen[key] {
+			seen[key] = true
+			scopes = append(scopes, key)
+		}
+	}
+
+	for _, key := range scopes {
+		found, err := listObjects(ctx, meta, key.gvk, key.namespace, options)
+		if isNoMatch(err) {
+			continue
+		}
+		if err != nil {
+			diags = append(diags, diag.Diagnostic{
+				Severity:      diag.Error,
+				Summary:       fmt.Sprintf("Failed to look for %s objects of the existing installation", key.gvk.Kind),
+				Detail:        errorDetail(err),
+				AttributePath: cty.GetAttrPath("adopt_existing"),
+			})
+			continue
+		}
+		for index := range found {
+			if !names[newInventoryItem(&found[index]).key()] {
+				lookalikes = append(lookalikes, fmt.Sprintf("  - %s %s", key.gvk.Kind, objectName(&found[index])))
+			}
+		}
+	}
+	return lookalikes, diags
+}
+
+// releaseHelm hands the adopted objects over from Helm, once they're applied. With
+// `helm_release_secrets = "delete"` the release's secrets are deleted and Helm's annotations
+// removed from the objects, so Helm no longer knows about them. Otherwise the release is
+// left alone, with a warning, since uninstalling it would delete the objects.
+func releaseHelm(ctx context.Context, d resourceSettings, meta interface{}, adopted adoption) diag.Diagnostics {
+	var diags diag.Diagnostics
+
+	if len(adopted.releases) == 0 {
+		return diags
+	}
+
+	if d.Get("helm_release_secrets").(string) != helmReleaseSecretsDelete {
+		for _, release := range adopted.releases {
+			diags = append(diags, diag.Diagnostic{
+				Severity: diag.Warning,
+				Summary:  fmt.Sprintf("The Helm release %s/%s still lists the adopted objects", release.Namespace, release.Name),
+				Detail: "Uninstalling the release with Helm would delete them. " +
+					"Set `helm_release_secrets = \"delete\"` to have the release removed when adopting.",
+				AttributePath: cty.GetAttrPath("helm_release_secrets"),
+			})
+		}
+		return diags
+	}
+
+	patch := []byte(fmt.Sprintf(`{"metadata":{"annotations":{%q:null,%q:null}}}`, helmReleaseNameAnnotation, helmReleaseNamespaceAnnotation))
+	for _, object := range adopted.helmObjects {
+		client, err := objectClient(meta, object)
+		if err == nil {
+			err = retryTransient(ctx, "removing the Helm annotations from "+object.GetKind()+" "+objectName(object), func() error {
+				_, err := client.Patch(ctx, object.GetName(), types.MergePatchType, patch, metav1.PatchOptions{})
+				return err
+			})
+		}
+		if err != nil {
+			diags = append(diags, diag.Diagnostic{
+				Severity:      diag.Error,
+				Summary:       fmt.Sprintf("Failed to remove the Helm annotations from %s %s", object.GetKind(), objectName(object)),
+				Detail:        errorDetail(err),
+				AttributePath: cty.GetAttrPath("helm_release_secrets"),
+			})
+		}
+	}
+
+	for _, release := range adopted.releases {
+		if err := deleteReleaseSecrets(ctx, meta, release); err != nil {
+			diags = append(diags, diag.Diagnostic{
+				Severity:      diag.Error,
+				Summary:       fmt.Sprintf("Failed to delete the secrets of the Helm release %s/%s", release.Namespace, release.Name),
+				Detail:        errorDetail(err),
+				AttributePath: cty.GetAttrPath("helm_release_secrets"),
+			})
+			continue
+		}
+		tflog.Info(ctx, fmt.Sprintf("Deleted the Helm release %s/%s", release.Namespace, release.Name))
+	}
+
+	return diags
+}
+
+// deleteReleaseSecrets deletes every revision Helm stored for the release.
+func deleteReleaseSecrets(ctx context.Context, meta interface{}, release helmRelease) error {
+	mapping, err := objectMapping(meta, secretKind)
+	if err != nil {
+		return err
+	}
+	client, err := resourceInterface(meta, mapping, release.Namespace)
+	if err != nil {
+		return err
+	}
+
+	var list *unstructured.UnstructuredList
+	err = retryTransient(ctx, "listing the secrets of the Helm release "+release.Name, func() error {
+		list, err = client.List(ctx, metav1.ListOptions{
+			LabelSelector: fmt.Sprintf("owner=helm,name=%s", release.Name),
+		})
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	for _, secret := range list.Items {
+		name := secret.GetName()
+		err := retryTransient(ctx, "deleting Secret "+release.Namespace+"/"+name, func() error {
+			return client.Delete(ctx, name, metav1.DeleteOptions{})
+		})
+		if err != nil && !apierrors.IsNotFound(err) {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,104 @@
+package octal
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/go-cty/cty"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestAdoptObjects(t *testing.T) {
+	helmObject := func(object *unstructured.Unstructured) *unstructured.Unstructured {
+		object.SetAnnotations(map[string]string{
+			helmReleaseNameAnnotation:      "cert-manager",
+			helmReleaseNamespaceAnnotation: "cert-manager",
+		})
+		return object
+	}
+	client := newTestApiClient(
+		helmObject(testObject(testServiceAccountKind, "cert-manager", "cert-manager", map[string]string{"app.kubernetes.io/version": "v1.8.2"})),
+		helmObject(testObject(testClusterRoleKind, "", "cert-manager-controller-issuers", map[string]string{"helm.sh/chart": "cert-manager-v1.8.2"})),
+		testObject(testSecretKind, "cert-manager", "sh.helm.release.v1.cert-manager.v1", map[string]string{"owner": "helm", "name": "cert-manager"}),
+		testObject(testSecretKind, "cert-manager", "unrelated", nil),
+		// Named by the upstream chart, unlike the bundle's own.
+		testObject(testClusterRoleKind, "", "cert-manager-controller-challenges", map[string]string{
+			"app.kubernetes.io/component": "controller", "app.kubernetes.io/managed-by": "Helm",
+		}),
+		testObject(testClusterRoleKind, "", "platform-controller-challenges", map[string]string{
+			"app.kubernetes.io/component": "controller", "app.kubernetes.io/managed-by": "terraform",
+		}),
+	)
+
+	d := schema.TestResourceDataRaw(t, resourceOctalCertManager().Schema, map[string]interface{}{
+		"namespace":            "cert-manager",
+		"adopt_existing":       true,
+		"helm_release_secrets": helmReleaseSecretsDelete,
+	})
+	d.SetId("instance")
+
+	objects := []bundleObject{
+		{bundle: certManagerBundle(), version: "1.8.2", component: "controller", object: testObject(testServiceAccountKind, "", "cert-manager", nil)},
+		{bundle: certManagerBundle(), version: "1.8.2", component: "controller", object: testObject(testClusterRoleKind, "", "cert-manager-controller-issuers", nil)},
+		{bundle: certManagerBundle(), version: "1.8.2", component: "controller", object: testObject(testDeploymentKind, "", "cert-manager", nil)},
+	}
+	// The bundle isn't installed next to the parts of the installation it can't adopt.
+	_, diags := adoptObjects(context.Background(), d, client, objects)
+	if len(diags) != 1 || diags[0].Severity != diag.Error ||
+		!strings.Contains(diags[0].Detail, "ClusterRole cert-manager-controller-challenges") || strings.Contains(diags[0].Detail, "platform-") {
+		t.Errorf("expected an error about the upstream ClusterRole only, got %#v", diags)
+	}
+
+	if err := deleteObject(context.Background(), client, testObject(testClusterRoleKind, "", "cert-manager-controller-challenges", nil)); err != nil {
+		t.Fatal(err)
+	}
+	for index := range objects {
+		objects[index].adopted = false
+	}
+	adopted, diags := adoptObjects(context.Background(), d, client, objects)
+	if len(diags) > 0 {
+		t.Fatalf("unexpected diagnostics: %#v", diags)
+	}
+	if !objects[0].adopted || !objects[1].adopted || objects[2].adopted {
+		t.Errorf("expected only the existing objects to be adopted, got %v %v %v", objects[0].adopted, objects[1].adopted, objects[2].adopted)
+	}
+	if len(adopted.releases) != 1 || adopted.releases[0] != (helmRelease{Name: "cert-manager", Namespace: "cert-manager"}) {
+		t.Fatalf("expected the cert-manager release, got %#v", adopted.releases)
+	}
+
+	keep := schema.TestResourceDataRaw(t, resourceOctalCertManager().Schema, map[string]interface{}{
+		"helm_release_secrets": helmReleaseSecretsKeep,
+	})
+	diags = releaseHelm(context.Background(), keep, client, adopted)
+	if len(diags) != 1 || diags[0].Severity != diag.Warning || !diags[0].AttributePath.Equals(cty.GetAttrPath("helm_release_secrets")) {
+		t.Errorf("expected a warning pointing at helm_release_secrets when keeping the release, got %#v", diags)
+	}
+
+	if diags := releaseHelm(context.Background(), d, client, adopted); diags.HasError() {
+		t.Fatalf("unexpected diagnostics: %#v", diags)
+	}
+	if _, err := getObject(context.Background(), client, testObject(testSecretKind, "cert-manager", "sh.helm.release.v1.cert-manager.v1", nil)); !apierrors.IsNotFound(err) {
+		t.Errorf("expected the release secret to be deleted, got %v", err)
+	}
+	if _, err := getObject(context.Background(), client, testObject(testSecretKind, "cert-manager", "unrelated", nil)); err != nil {
+		t.Errorf("expected the unrelated secret to be kept, got %v", err)
+	}
+	live, err := getObject(context.Background(), client, objects[0].object)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := live.GetAnnotations()[helmReleaseNameAnnotation]; ok {
+		t.Errorf("expected the Helm annotations to be removed, got %v", live.GetAnnotations())
+	}
+
+	for index := range objects {
+		objects[index].version = "1.9.1"
+	}
+	if _, diags := adoptObjects(context.Background(), d, client, objects); !diags.HasError() {
+		t.Error("expected an error adopting objects of another version")
+	}
+}
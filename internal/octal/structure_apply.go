@@ -0,0 +1,310 @@
+package octal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/go-cty/cty"
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	k8sschema "k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+const defaultFieldManager = "terraform-provider-octal"
+
+// bundleObject is a single manifest from a component bundle, tagged with the bundle and
+// the component it belongs to so its metadata can be customized from that component's block.
+type bundleObject struct {
+	bundle *bundleDefinition
+	// version is the release of the bundle the manifest comes from.
+	version   string
+	component string
+	object    *unstructured.Unstructured
+	// adopted is set for objects taken over from another installation. Their fields are
+	// owned by whatever installed them, so they're applied with force.
+	adopted bool
+}
+
+// newBundleObject converts a typed manifest into the unstructured form that gets applied.
+func newBundleObject(bundle *bundleDefinition, version string, component string, obj runtime.Object) (bundleObject, error) {
+	content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+	if err != nil {
+		return bundleObject{}, err
+	}
+
+	object := &unstructured.Unstructured{Object: content}
+	// Neither belongs in an apply configuration; sending them would only claim ownership
+	// of fields the API server manages.
+	unstructured.RemoveNestedField(object.Object, "metadata", "creationTimestamp")
+	unstructured.RemoveNestedField(object.Object, "status")
+
+	return bundleObject{bundle: bundle, version: version, component: component, object: object}, nil
+}
+
+// prepareObject applies the resource's settings to a manifest: the labels and annotations
+// from its component's block, and the namespace when its kind is namespaced.
+func prepareObject(ctx context.Context, d resourceSettings, meta interface{}, object bundleObject) error {
+	mapping, err := objectMapping(meta, object.object.GroupVersionKind())
+	if err != nil {
+		return err
+	}
+
+	customizeObject(ctx, d, object, isNamespaced(mapping))
+	return nil
+}
+
+func customizeObject(ctx context.Context, d resourceSettings, object bundleObject, namespaced bool) {
+	if !namespaced {
+		object.object.SetNamespace("")
+	}
+	updateMetadata(ctx, object.bundle.instanceLabel(), object.version, object.component, namespaced, object.object, d)
+}
+
+// renderBundle collects the bundle and prepares every object in it. Custom resources whose
+// CRD ships in the same bundle may not be served yet, so their scope is taken from the CRD.
+func renderBundle(ctx context.Context, bundle *bundleDefinition, d resourceSettings, meta interface{}) ([]bundleObject, error) {
+	objects, err := bundle.objects(ctx, d, meta)
+	if err != nil {
+		return nil, err
+	}
+	if err := prepareBundle(ctx, d, meta, objects); err != nil {
+		return nil, err
+	}
+	return objects, nil
+}
+
+// prepareBundle prepares every object of a collected bundle.
+func prepareBundle(ctx context.Context, d resourceSettings, meta interface{}, objects []bundleObject) error {
+	scopes := customResourceScopes(objects)
+	for _, object := range objects {
+		if namespaced, ok := scopes[object.object.GroupVersionKind().GroupKind()]; ok {
+			customizeObject(ctx, d, object, namespaced)
+			continue
+		}
+		if err := prepareObject(ctx, d, meta, object); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// customResourceScopes returns whether the kinds defined by the bundle's CRDs are namespaced.
+func customResourceScopes(objects []bundleObject) map[k8sschema.GroupKind]bool {
+	scopes := map[k8sschema.GroupKind]bool{}
+	for _, object := range objects {
+		if objectPhase(object.object) != phaseCustomResourceDefinitions {
+			continue
+		}
+		group, _, _ := unstructured.NestedString(object.object.Object, "spec", "group")
+		kind, _, _ := unstructured.NestedString(object.object.Object, "spec", "names", "kind")
+		scope, _, _ := unstructured.NestedString(object.object.Object, "spec", "scope")
+		scopes[k8sschema.GroupKind{Group: group, Kind: kind}] = scope == "Namespaced"
+	}
+	return scopes
+}
+
+// applyObjects applies the bundle phase by phase. A phase only starts once the one before
+// it went through, since its objects may depend on those. Within a phase, objects are
+// applied concurrently, up to the provider's `parallelism`. It returns the inventory of the
+// objects that were applied, even when others failed.
+func applyObjects(ctx context.Context, d *schema.ResourceData, meta interface{}, objects []bundleObject) ([]inventoryItem, diag.Diagnostics) {
+	var diags diag.Diagnostics
+	var inventory []inventoryItem
+
+	for phase, phaseObjects := range groupByPhase(objects) {
+		if len(phaseObjects) == 0 {
+			continue
+		}
+		tflog.Info(ctx, fmt.Sprintf("Applying %s", applyPhase(phase)))
+
+		/*******************************\
+		** Update Manifest MetaData    **
+		\*******************************/
+		var pending []bundleObject
+		for _, object := range phaseObjects {
+			if err := prepareObject(ctx, d, meta, object); err != nil {
+				diags = append(diags, applyDiagnostic(object, err))
+				continue
+			}
+			pending = append(pending, object)
+		}
+
+		/*******************************\
+		** Apply Kubernetes Objects    **
+		\*******************************/
+		// Objects of the same phase don't depend on each other, so they're applied at once.
+		applied := make([]*unstructured.Unstructured, len(pending))
+		errs := runPool(ctx, parallelism(meta), len(pending), func(ctx context.Context, index int) error {
+			var err error
+			applied[index], err = applyObject(ctx, meta, pending[index].object, pending[index].adopted)
+			return err
+		})
+		for index, object := range pending {
+			if errs[index] != nil {
+				diags = append(diags, applyDiagnostic(object, errs[index]))
+				continue
+			}
+			inventory = append(inventory, newInventoryItem(applied[index]))
+			tflog.Info(ctx, fmt.Sprintf("Applied %s %s", object.object.GetKind(), objectName(object.object)))
+		}
+		if diags.HasError() {
+			return inventory, diags
+		}
+
+		// Custom resources later in the bundle can only be applied once the API server
+		// serves their kinds.
+		if applyPhase(phase) == phaseCustomResourceDefinitions {
+			for _, object := range phaseObjects {
+				if err := waitForEstablished(ctx, meta, object.object); err != nil {
+					diags = append(diags, diag.Diagnostic{
+						Severity:      diag.Error,
+						Summary:       fmt.Sprintf("CustomResourceDefinition %s was not established", object.object.GetName()),
+						Detail:        errorDetail(err),
+						AttributePath: componentPath(object.component),
+					})
+				}
+			}
+			if diags.HasError() {
+				return inventory, diags
+			}
+			resetRESTMapper(meta)
+		}
+	}
+
+	return inventory, diags
+}
+
+// deleteObjects deletes the bundle in the reverse of the order it's applied in, so nothing
+// is left running against objects that are already gone. A webhook, for one, is removed
+// before the pods that serve it.
+func deleteObjects(ctx context.Context, meta interface{}, objects []bundleObject) diag.Diagnostics {
+	var diags diag.Diagnostics
+
+	phases := groupByPhase(objects)
+	for phase := len(phases) - 1; phase >= 0; phase-- {
+		phaseObjects := phases[phase]
+		errs := runPool(ctx, parallelism(meta), len(phaseObjects), func(ctx context.Context, index int) error {
+			return deleteObject(ctx, meta, phaseObjects[index].object)
+		})
+		for index, object := range phaseObjects {
+			if errs[index] != nil {
+				diags = append(diags, deleteDiagnostic(object, errs[index]))
+				continue
+			}
+			tflog.Info(ctx, fmt.Sprintf("Deleted %s %s", object.object.GetKind(), objectName(object.object)))
+		}
+		if diags.HasError() {
+			return diags
+		}
+	}
+
+	return diags
+}
+
+// applyDiagnostic explains why an apply failed. Field ownership conflicts list each
+// contested field along with the manager that owns it.
+func applyDiagnostic(object bundleObject, err error) diag.Diagnostic {
+	if apierrors.IsConflict(err) {
+		var conflicts []string
+		if status, ok := err.(apierrors.APIStatus); ok && status.Status().Details != nil {
+			for _, cause := range status.Status().Details.Causes {
+				if cause.Type == metav1.CauseTypeFieldManagerConflict {
+					conflicts = append(conflicts, fmt.Sprintf("  - %s: %s", cause.Field, cause.Message))
+				}
+			}
+		}
+		if len(conflicts) > 0 {
+			return diag.Diagnostic{
+				Severity: diag.Error,
+				Summary:  fmt.Sprintf("Field ownership conflict applying %s %s", object.object.GetKind(), objectName(object.object)),
+				Detail: fmt.Sprintf("Fields this provider sets are owned by another field manager:\n\n%s\n\n"+
+					"Remove the fields from the other manager, or set `force_conflicts = true` on the provider to take ownership of them.",
+					strings.Join(conflicts, "\n")),
+				AttributePath: componentPath(object.component),
+			}
+		}
+	}
+
+	return diag.Diagnostic{
+		Severity:      diag.Error,
+		Summary:       fmt.Sprintf("Failed to apply %s %s", object.object.GetKind(), objectName(object.object)),
+		Detail:        errorDetail(err),
+		AttributePath: componentPath(object.component),
+	}
+}
+
+func deleteDiagnostic(object bundleObject, err error) diag.Diagnostic {
+	return diag.Diagnostic{
+		Severity:      diag.Error,
+		Summary:       fmt.Sprintf("Failed to delete %s %s", object.object.GetKind(), objectName(object.object)),
+		Detail:        errorDetail(err),
+		AttributePath: componentPath(object.component),
+	}
+}
+
+// renderDiagnostic reports a bundle that couldn't be rendered, e.g. because the cluster
+// couldn't tell which kinds are namespaced.
+func renderDiagnostic(bundle *bundleDefinition, err error) diag.Diagnostic {
+	return diag.Diagnostic{
+		Severity: diag.Error,
+		Summary:  fmt.Sprintf("Failed to render the %s bundle", bundle.Name),
+		Detail:   errorDetail(err),
+	}
+}
+
+// readDiagnostic reports an object that couldn't be read. Objects only known from the
+// inventory point at it rather than at a component.
+func readDiagnostic(object bundleObject, err error) diag.Diagnostic {
+	path := componentPath(object.component)
+	if path == nil {
+		path = cty.GetAttrPath("inventory")
+	}
+	return diag.Diagnostic{
+		Severity:      diag.Error,
+		Summary:       fmt.Sprintf("Failed to read %s %s", object.object.GetKind(), objectName(object.object)),
+		Detail:        errorDetail(err),
+		AttributePath: path,
+	}
+}
+
+// errorDetail describes an error, along with the reason and HTTP status the API server
+// gave when it came from there.
+func errorDetail(err error) string {
+	var status apierrors.APIStatus
+	if !errors.As(err, &status) || status.Status().Code == 0 {
+		return err.Error()
+	}
+	reason := apierrors.ReasonForError(err)
+	if reason == metav1.StatusReasonUnknown {
+		reason = "Unknown"
+	}
+	return fmt.Sprintf("%s\n\nThe API server responded with %s (HTTP %d).", err, reason, status.Status().Code)
+}
+
+// componentPath points at the configuration an object comes from: the block of its
+// component, or the `namespace` attribute for the namespace itself. Objects only known from
+// the inventory have no component.
+func componentPath(component string) cty.Path {
+	switch component {
+	case "":
+		return nil
+	case "namespace":
+		return cty.GetAttrPath("namespace")
+	}
+	return cty.GetAttrPath(component).IndexInt(0)
+}
+
+// objectName formats an object's name the way kubectl does, namespace first.
+func objectName(object metav1.Object) string {
+	if object.GetNamespace() == "" {
+		return object.GetName()
+	}
+	return object.GetNamespace() + "/" + object.GetName()
+}
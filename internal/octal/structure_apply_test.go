@@ -0,0 +1,55 @@
+package octal
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/go-cty/cty"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestApplyDiagnostic(t *testing.T) {
+	object := &unstructured.Unstructured{}
+	object.SetAPIVersion("apps/v1")
+	object.SetKind("Deployment")
+	object.SetNamespace("cert-manager")
+	object.SetName("cert-manager")
+
+	conflict := apierrors.NewApplyConflict([]metav1.StatusCause{
+		{
+			Type:    metav1.CauseTypeFieldManagerConflict,
+			Field:   ".spec.replicas",
+			Message: `conflict with "kubectl-scale" using apps/v1`,
+		},
+	}, "Apply failed with 1 conflict")
+
+	d := applyDiagnostic(bundleObject{component: "controller", object: object}, conflict)
+	if d.Summary != "Field ownership conflict applying Deployment cert-manager/cert-manager" {
+		t.Errorf("unexpected summary %q", d.Summary)
+	}
+	if !strings.Contains(d.Detail, `.spec.replicas: conflict with "kubectl-scale"`) {
+		t.Errorf("expected the detail to name the conflicting manager, got %q", d.Detail)
+	}
+
+	if !d.AttributePath.Equals(cty.GetAttrPath("controller").IndexInt(0)) {
+		t.Errorf("expected the diagnostic to point at the controller block, got %#v", d.AttributePath)
+	}
+
+	d = applyDiagnostic(bundleObject{component: "controller", object: object}, errors.New("connection refused"))
+	if d.Summary != "Failed to apply Deployment cert-manager/cert-manager" || d.Detail != "connection refused" {
+		t.Errorf("unexpected diagnostic %#v", d)
+	}
+
+	forbidden := apierrors.NewForbidden(schema.GroupResource{Group: "apps", Resource: "deployments"}, "cert-manager", errors.New("no RBAC policy matched"))
+	d = applyDiagnostic(bundleObject{object: object}, forbidden)
+	if !strings.Contains(d.Detail, "The API server responded with Forbidden (HTTP 403).") {
+		t.Errorf("expected the detail to carry the status reason, got %q", d.Detail)
+	}
+	if d.AttributePath != nil {
+		t.Errorf("expected no attribute path without a component, got %#v", d.AttributePath)
+	}
+}
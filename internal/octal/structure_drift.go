@@ -0,0 +1,143 @@
+package octal
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+const (
+	// driftModeCorrect plans an update that applies the bundle again.
+	driftModeCorrect = "correct"
+	// driftModeWarn only reports drift as a warning when refreshing.
+	driftModeWarn = "warn"
+	// driftModeIgnore doesn't look for drift at all.
+	driftModeIgnore = "ignore"
+)
+
+// objectDrift compares the live object with the rendered one and returns every field that
+// differs, prefixed with the object it belongs to. Only fields the rendered object sets are
+// compared, since those are the fields the provider manages; whatever defaults the API
+// server or other controllers fill in is left alone.
+func objectDrift(desired *unstructured.Unstructured, live *unstructured.Unstructured) []string {
+	var fields []string
+
+	desiredContent := desired.UnstructuredContent()
+	liveContent := live.UnstructuredContent()
+	for _, key := range sortedKeys(desiredContent) {
+		if key == "status" {
+			continue
+		}
+		fields = append(fields, fieldDrift("."+key, desiredContent[key], liveContent[key])...)
+	}
+
+	name := fmt.Sprintf("%s %s", desired.GetKind(), objectName(desired))
+	for index := range fields {
+		fields[index] = fmt.Sprintf("%s: %s", name, fields[index])
+	}
+	return fields
+}
+
+// fieldDrift returns the paths below path where live doesn't match desired.
+func fieldDrift(path string, desired interface{}, live interface{}) []string {
+	if isEmptyValue(desired) && isEmptyValue(live) {
+		return nil
+	}
+
+	switch desired := desired.(type) {
+	case map[string]interface{}:
+		liveMap, ok := live.(map[string]interface{})
+		if !ok {
+			return []string{path}
+		}
+		var fields []string
+		for _, key := range sortedKeys(desired) {
+			fields = append(fields, fieldDrift(path+"."+key, desired[key], liveMap[key])...)
+		}
+		return fields
+
+	case []interface{}:
+		liveList, ok := live.([]interface{})
+		if !ok || len(liveList) != len(desired) {
+			return []string{path}
+		}
+		var fields []string
+		for index := range desired {
+			fields = append(fields, fieldDrift(fmt.Sprintf("%s[%d]", path, index), desired[index], liveList[index])...)
+		}
+		return fields
+	}
+
+	if !reflect.DeepEqual(desired, live) {
+		return []string{path}
+	}
+	return nil
+}
+
+// isEmptyValue reports whether the value is unset, which the API server doesn't
+// distinguish from empty for maps and lists.
+func isEmptyValue(value interface{}) bool {
+	switch value := value.(type) {
+	case nil:
+		return true
+	case map[string]interface{}:
+		return len(value) == 0
+	case []interface{}:
+		return len(value) == 0
+	}
+	return false
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// readDrift renders the bundle and compares it with the live copies of the inventoried
+// objects. Objects that aren't part of the bundle anymore are left to pruning.
+func readDrift(ctx context.Context, bundle *bundleDefinition, d *schema.ResourceData, meta interface{}, live []*unstructured.Unstructured) ([]string, diag.Diagnostics) {
+	objects, err := renderBundle(ctx, bundle, d, meta)
+	if err != nil {
+		return nil, diag.Diagnostics{renderDiagnostic(bundle, err)}
+	}
+
+	desired := map[string]*unstructured.Unstructured{}
+	for _, object := range objects {
+		desired[newInventoryItem(object.object).key()] = object.object
+	}
+
+	var drift []string
+	for _, object := range live {
+		if rendered, ok := desired[newInventoryItem(object).key()]; ok {
+			drift = append(drift, objectDrift(rendered, object)...)
+		}
+	}
+	return drift, nil
+}
+
+// customizeDiffDrift plans an update that corrects the drift found by the last refresh.
+func customizeDiffDrift(ctx context.Context, d *schema.ResourceDiff, meta interface{}) error {
+	if d.Id() == "" || d.Get("drift_mode").(string) != driftModeCorrect {
+		return nil
+	}
+
+	drift := d.Get("drift").([]interface{})
+	if len(drift) == 0 {
+		return nil
+	}
+
+	tflog.Info(ctx, fmt.Sprintf("Correcting drift in %s", strings.Join(expandStringList(drift), ", ")))
+
+	return d.SetNew("drift", []string{})
+}
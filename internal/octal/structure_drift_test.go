@@ -0,0 +1,71 @@
+package octal
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/dylanturn/terraform-provider-octal/internal/util"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestObjectDrift(t *testing.T) {
+	manifest := util.Manifest{Path: "v1.8.2/deployment.yml", Content: []byte(`apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: cert-manager
+  namespace: cert-manager
+  labels:
+    app: cert-manager
+spec:
+  replicas: 1
+  template:
+    spec:
+      containers:
+      - name: controller
+        image: quay.io/jetstack/cert-manager-controller:v1.8.2
+        resources: {}
+        ports:
+        - containerPort: 9402
+`)}
+	objects, err := manifest.DecodeObjects()
+	if err != nil {
+		t.Fatal(err)
+	}
+	desired := &objects[0]
+
+	// The live copy is decoded from JSON, the way the dynamic client reads it.
+	content, err := desired.MarshalJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	live := &unstructured.Unstructured{}
+	if err := live.UnmarshalJSON(content); err != nil {
+		t.Fatal(err)
+	}
+	// Fields the API server fills in aren't drift.
+	live.SetUID("uid")
+	unstructured.SetNestedField(live.Object, int64(600), "spec", "progressDeadlineSeconds")
+	unstructured.SetNestedField(live.Object, int64(1), "status", "replicas")
+	containers, _, _ := unstructured.NestedSlice(live.Object, "spec", "template", "spec", "containers")
+	delete(containers[0].(map[string]interface{}), "resources")
+	containers[0].(map[string]interface{})["terminationMessagePath"] = "/dev/termination-log"
+	unstructured.SetNestedSlice(live.Object, containers, "spec", "template", "spec", "containers")
+
+	if drift := objectDrift(desired, live); len(drift) != 0 {
+		t.Fatalf("expected no drift, got %v", drift)
+	}
+
+	unstructured.SetNestedField(live.Object, int64(3), "spec", "replicas")
+	containers[0].(map[string]interface{})["image"] = "quay.io/jetstack/cert-manager-controller:v1.9.1"
+	unstructured.SetNestedSlice(live.Object, containers, "spec", "template", "spec", "containers")
+	live.SetLabels(map[string]string{"app": "edited"})
+
+	expected := []string{
+		"Deployment cert-manager/cert-manager: .metadata.labels.app",
+		"Deployment cert-manager/cert-manager: .spec.replicas",
+		"Deployment cert-manager/cert-manager: .spec.template.spec.containers[0].image",
+	}
+	if drift := objectDrift(desired, live); !reflect.DeepEqual(drift, expected) {
+		t.Fatalf("expected %v, got %v", expected, drift)
+	}
+}
@@ -0,0 +1,199 @@
+package octal
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	k8sschema "k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// resourceBundleImport brings an installation back under management from an id
+// of the form `<namespace>/<instance-id>`. The objects are found by their instance labels,
+// for every kind the bundle ships, and the settings that can be read off them are restored.
+func resourceBundleImport(bundle *bundleDefinition) schema.StateContextFunc {
+	return func(ctx context.Context, d *schema.ResourceData, meta interface{}) ([]*schema.ResourceData, error) {
+		parts := strings.Split(d.Id(), "/")
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return nil, fmt.Errorf("unexpected import id %q, expected <namespace>/<instance-id>", d.Id())
+		}
+		namespace, instance := parts[0], parts[1]
+
+		d.SetId(instance)
+		d.Set("namespace", namespace)
+		// Import starts from empty state. The kinds to look for are those of the default
+		// version, until the labels of the objects tell which version is installed.
+		d.Set("name", bundle.Name)
+		d.Set("version", bundle.DefaultVersion)
+
+		client, err := resourceClient(d, meta)
+		if err != nil {
+			return nil, err
+		}
+
+		objects, err := bundle.objects(ctx, d, client)
+		if err != nil {
+			return nil, err
+		}
+		var kinds []k8sschema.GroupVersionKind
+		seen := map[k8sschema.GroupVersionKind]bool{}
+		for _, object := range objects {
+			gvk := object.object.GroupVersionKind()
+			if !seen[gvk] {
+				seen[gvk] = true
+				kinds = append(kinds, gvk)
+			}
+		}
+
+		var inventory []inventoryItem
+		var live []*unstructured.Unstructured
+		inNamespace := false
+		for _, gvk := range kinds {
+			found, err := listObjects(ctx, client, gvk, "", bundle.listOptions(instance))
+			if isNoMatch(err) {
+				// The kind of a CRD the installation never got to create.
+				continue
+			}
+			if err != nil {
+				return nil, fmt.Errorf("failed to list %s objects: %s", gvk.Kind, err)
+			}
+
+			for index := range found {
+				object := &found[index]
+				inventory = append(inventory, newInventoryItem(object))
+				live = append(live, object)
+
+				if object.GetNamespace() == namespace || (gvk.Kind == "Namespace" && object.GetName() == namespace) {
+					inNamespace = true
+				}
+
+				labels := object.GetLabels()
+				if name := labels["app.kubernetes.io/part-of"]; name != "" {
+					d.Set("name", name)
+				}
+				if version := labels["app.kubernetes.io/version"]; version != "" {
+					d.Set("version", version)
+				}
+			}
+		}
+
+		if len(inventory) == 0 {
+			return nil, fmt.Errorf("no objects labelled with the instance id %q were found", instance)
+		}
+		if !inNamespace {
+			return nil, fmt.Errorf("none of the objects of instance %q are in the namespace %q", instance, namespace)
+		}
+
+		d.Set("inventory", flattenInventory(inventory))
+
+		// The component blocks are read back from the objects, as they are at the version
+		// that's installed.
+		manifests, err := bundle.objects(ctx, d, client)
+		if err != nil {
+			return nil, err
+		}
+		for component, metadata := range componentMetadata(bundle, manifests, live) {
+			d.Set(component, []interface{}{metadata})
+		}
+
+		// Import doesn't apply defaults, and these can't be read off the cluster.
+		d.Set("prune", true)
+		d.Set("drift_mode", driftModeCorrect)
+		d.Set("on_failure", onFailureKeep)
+		d.Set("adopt_existing", false)
+		d.Set("helm_release_secrets", helmReleaseSecretsKeep)
+		for _, parameter := range bundle.Parameters {
+			d.Set(parameter.Name, parameter.Default)
+		}
+
+		return []*schema.ResourceData{d}, nil
+	}
+}
+
+// systemAnnotations are set by the API server and controllers, not by the bundle or the
+// resource.
+var systemAnnotations = map[string]bool{
+	"deployment.kubernetes.io/revision":                true,
+	"kubectl.kubernetes.io/last-applied-configuration": true,
+}
+
+// componentMetadata reads the `labels` and `annotations` of every component block off the
+// live objects. A block's labels and annotations go on every object of its component, so
+// they're the ones all of its objects share, less those the provider manages and those the
+// manifest of the object sets itself.
+func componentMetadata(bundle *bundleDefinition, manifests []bundleObject, live []*unstructured.Unstructured) map[string]map[string]interface{} {
+	// Manifests don't name the namespace of namespaced objects yet.
+	manifestKey := func(object *unstructured.Unstructured) string {
+		return object.GroupVersionKind().GroupKind().String() + "/" + object.GetName()
+	}
+	blocks := map[string]bool{}
+	for _, component := range bundle.Components {
+		blocks[component.Component.GetName()] = true
+	}
+	rendered := map[string]bundleObject{}
+	for _, manifest := range manifests {
+		if blocks[manifest.component] {
+			rendered[manifestKey(manifest.object)] = manifest
+		}
+	}
+
+	providerLabel := func(key string) bool {
+		return strings.HasPrefix(key, "app.kubernetes.io/") || key == bundle.instanceLabel()
+	}
+
+	labels := map[string]map[string]string{}
+	annotations := map[string]map[string]string{}
+	for _, object := range live {
+		manifest, ok := rendered[manifestKey(object)]
+		if !ok {
+			continue
+		}
+
+		component := manifest.component
+		labels[component] = sharedMetadata(labels[component], userMetadata(object.GetLabels(), manifest.object.GetLabels(), providerLabel))
+		annotations[component] = sharedMetadata(annotations[component], userMetadata(object.GetAnnotations(), manifest.object.GetAnnotations(), func(key string) bool {
+			return systemAnnotations[key]
+		}))
+	}
+
+	metadata := map[string]map[string]interface{}{}
+	for name := range blocks {
+		metadata[name] = map[string]interface{}{
+			"labels":      labels[name],
+			"annotations": annotations[name],
+		}
+	}
+	return metadata
+}
+
+// userMetadata returns the labels or annotations of a live object that were neither set by
+// its manifest, with the same value, nor are skipped.
+func userMetadata(live map[string]string, manifest map[string]string, skip func(key string) bool) map[string]string {
+	user := map[string]string{}
+	for key, value := range live {
+		if skip(key) {
+			continue
+		}
+		if manifestValue, ok := manifest[key]; ok && manifestValue == value {
+			continue
+		}
+		user[key] = value
+	}
+	return user
+}
+
+// sharedMetadata returns what shared, the metadata of the objects so far, has in common with
+// metadata. A nil shared means there were no objects so far.
+func sharedMetadata(shared map[string]string, metadata map[string]string) map[string]string {
+	if shared == nil {
+		return metadata
+	}
+	for key, value := range shared {
+		if metadata[key] != value {
+			delete(shared, key)
+		}
+	}
+	return shared
+}
@@ -0,0 +1,158 @@
+package octal
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func TestResourceOctalCertManagerImport(t *testing.T) {
+	labels := map[string]string{
+		"project-octal.io/cert-manager-schema": "instance",
+		"app.kubernetes.io/instance":           "instance",
+		"app.kubernetes.io/part-of":            "platform-cert-manager",
+		"app.kubernetes.io/version":            "1.8.2",
+	}
+	client := newTestApiClient(
+		testObject(testNamespaceKind, "", "platform", labels),
+		testObject(testClusterRoleKind, "", "cert-manager-controller-issuers", labels),
+		testObject(testClusterRoleKind, "", "someone-elses", nil),
+	)
+
+	d := schema.TestResourceDataRaw(t, resourceOctalCertManager().Schema, map[string]interface{}{})
+	d.SetId("platform/instance")
+
+	imported, err := resourceOctalCertManager().Importer.StateContext(context.Background(), d, client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d = imported[0]
+
+	if d.Id() != "instance" || d.Get("namespace") != "platform" {
+		t.Errorf("unexpected id %q and namespace %q", d.Id(), d.Get("namespace"))
+	}
+	if d.Get("name") != "platform-cert-manager" || d.Get("version") != "1.8.2" {
+		t.Errorf("unexpected name %q and version %q", d.Get("name"), d.Get("version"))
+	}
+	if inventory := expandInventory(d.Get("inventory").([]interface{})); len(inventory) != 2 {
+		t.Errorf("expected the Namespace and the ClusterRole in the inventory, got %#v", inventory)
+	}
+
+	// Terraform imports into empty state, without the defaults of the schema. Any default
+	// the importer doesn't set plans an update right after the import.
+	fresh := resourceOctalCertManager().Data(nil)
+	fresh.SetId("platform/instance")
+	imported, err = resourceOctalCertManager().Importer.StateContext(context.Background(), fresh, client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	attributes := imported[0].State().Attributes
+	for key, expected := range map[string]string{
+		"prune":                "true",
+		"drift_mode":           driftModeCorrect,
+		"on_failure":           onFailureKeep,
+		"adopt_existing":       "false",
+		"helm_release_secrets": helmReleaseSecretsKeep,
+		"version":              "1.8.2",
+	} {
+		if value, ok := attributes[key]; !ok || value != expected {
+			t.Errorf("expected the import to set %s to %q, got %q", key, expected, value)
+		}
+	}
+
+	d.SetId("elsewhere/instance")
+	if _, err := resourceOctalCertManager().Importer.StateContext(context.Background(), d, client); err == nil {
+		t.Error("expected an error when none of the objects are in the namespace")
+	}
+
+	d.SetId("instance")
+	if _, err := resourceOctalCertManager().Importer.StateContext(context.Background(), d, client); err == nil {
+		t.Error("expected an error for an id without a namespace")
+	}
+}
+
+func TestResourceOctalCertManagerImportComponents(t *testing.T) {
+	ctx := context.Background()
+	bundle := certManagerBundle()
+	configured := schema.TestResourceDataRaw(t, resourceOctalCertManager().Schema, map[string]interface{}{
+		"namespace": "platform",
+		"controller": []interface{}{map[string]interface{}{
+			"labels":      map[string]interface{}{"team": "platform"},
+			"annotations": map[string]interface{}{"owner": "platform-team"},
+		}},
+	})
+	configured.SetId("instance")
+
+	// The installation as the configuration above applied it, of the kinds the test client knows.
+	client := newTestApiClient()
+	objects, err := bundle.objects(ctx, configured, client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var live []runtime.Object
+	seen := map[string]bool{}
+	for _, object := range objects {
+		if err := prepareObject(ctx, configured, client, object); err != nil {
+			continue
+		}
+		// A later manifest of the same name overwrites an earlier one.
+		key := newInventoryItem(object.object).key()
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		if object.component == "controller" {
+			annotations := object.object.GetAnnotations()
+			annotations["kubectl.kubernetes.io/last-applied-configuration"] = "{}"
+			object.object.SetAnnotations(annotations)
+		}
+		live = append(live, object.object)
+	}
+	client = newTestApiClient(live...)
+
+	d := resourceOctalCertManager().Data(nil)
+	d.SetId("platform/instance")
+	imported, err := resourceOctalCertManager().Importer.StateContext(ctx, d, client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d = imported[0]
+
+	for _, component := range bundle.Components {
+		name := component.Component.GetName()
+		for _, field := range []string{"labels", "annotations"} {
+			expected := configured.Get(name + ".0." + field)
+			if actual := d.Get(name + ".0." + field); !reflect.DeepEqual(actual, expected) {
+				t.Errorf("expected the %s of %s to be imported as %v, got %v", field, name, expected, actual)
+			}
+		}
+	}
+
+	// Rendered from the imported state, the objects carry the labels and annotations they
+	// have on the cluster.
+	rendered, err := bundle.objects(ctx, d, client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, object := range live {
+		expected := object.(*unstructured.Unstructured)
+		for _, manifest := range rendered {
+			if manifest.object.GroupVersionKind() != expected.GroupVersionKind() || manifest.object.GetName() != expected.GetName() {
+				continue
+			}
+			if err := prepareObject(ctx, d, client, manifest); err != nil {
+				t.Fatal(err)
+			}
+			annotations := expected.GetAnnotations()
+			delete(annotations, "kubectl.kubernetes.io/last-applied-configuration")
+			if !reflect.DeepEqual(manifest.object.GetLabels(), expected.GetLabels()) || !reflect.DeepEqual(manifest.object.GetAnnotations(), annotations) {
+				t.Errorf("expected %s %s to render as %v %v, got %v %v", expected.GetKind(), expected.GetName(),
+					expected.GetLabels(), annotations, manifest.object.GetLabels(), manifest.object.GetAnnotations())
+			}
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package octal
+
+import (
+	"context"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	k8sschema "k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// inventoryItem identifies one object the resource applied. The inventory is kept in state
+// so every object can be addressed directly, whatever labels it carries now.
+type inventoryItem struct {
+	Group     string
+	Version   string
+	Kind      string
+	Namespace string
+	Name      string
+	UID       string
+}
+
+func newInventoryItem(object *unstructured.Unstructured) inventoryItem {
+	gvk := object.GroupVersionKind()
+	return inventoryItem{
+		Group:     gvk.Group,
+		Version:   gvk.Version,
+		Kind:      gvk.Kind,
+		Namespace: object.GetNamespace(),
+		Name:      object.GetName(),
+		UID:       string(object.GetUID()),
+	}
+}
+
+// object returns a stub of the object, enough to address it on the cluster.
+func (i inventoryItem) object() *unstructured.Unstructured {
+	object := &unstructured.Unstructured{}
+	object.SetGroupVersionKind(k8sschema.GroupVersionKind{Group: i.Group, Version: i.Version, Kind: i.Kind})
+	object.SetNamespace(i.Namespace)
+	object.SetName(i.Name)
+	return object
+}
+
+// key identifies the object regardless of API version and uid.
+func (i inventoryItem) key() string {
+	return i.Group + "/" + i.Kind + "/" + i.Namespace + "/" + i.Name
+}
+
+func flattenInventory(inventory []inventoryItem) []interface{} {
+	flat := make([]interface{}, len(inventory))
+	for index, item := range inventory {
+		flat[index] = map[string]interface{}{
+			"group":     item.Group,
+			"version":   item.Version,
+			"kind":      item.Kind,
+			"namespace": item.Namespace,
+			"name":      item.Name,
+			"uid":       item.UID,
+		}
+	}
+	return flat
+}
+
+func expandInventory(l []interface{}) []inventoryItem {
+	inventory := make([]inventoryItem, 0, len(l))
+	for _, v := range l {
+		item, ok := v.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		inventory = append(inventory, inventoryItem{
+			Group:     item["group"].(string),
+			Version:   item["version"].(string),
+			Kind:      item["kind"].(string),
+			Namespace: item["namespace"].(string),
+			Name:      item["name"].(string),
+			UID:       item["uid"].(string),
+		})
+	}
+	return inventory
+}
+
+// inventoryObjects turns the inventory back into objects that can be deleted.
+func inventoryObjects(inventory []inventoryItem) []bundleObject {
+	objects := make([]bundleObject, len(inventory))
+	for index, item := range inventory {
+		objects[index] = bundleObject{object: item.object()}
+	}
+	return objects
+}
+
+// readInventory reads every inventoried object. Objects that no longer exist are dropped
+// from the returned inventory, and objects that were recreated get their new uid. Objects
+// that can't be read stay in the inventory as they were, with a diagnostic each.
+func readInventory(ctx context.Context, meta interface{}, inventory []inventoryItem) ([]inventoryItem, []*unstructured.Unstructured, diag.Diagnostics) {
+	var current []inventoryItem
+	var live []*unstructured.Unstructured
+	var diags diag.Diagnostics
+
+	for _, item := range inventory {
+		object, err := getObject(ctx, meta, item.object())
+		if apierrors.IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			diags = append(diags, readDiagnostic(bundleObject{object: item.object()}, err))
+			current = append(current, item)
+			continue
+		}
+		current = append(current, newInventoryItem(object))
+		live = append(live, object)
+	}
+
+	return current, live, diags
+}
+
+// discoverInventory finds the objects of a bundle that exist on the cluster. It's used for
+// installations created before the inventory was recorded in state.
+func discoverInventory(ctx context.Context, meta interface{}, objects []bundleObject) ([]inventoryItem, diag.Diagnostics) {
+	var inventory []inventoryItem
+	var diags diag.Diagnostics
+
+	for _, object := range objects {
+		live, err := getObject(ctx, meta, object.object)
+		if apierrors.IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			diags = append(diags, readDiagnostic(object, err))
+			continue
+		}
+		inventory = append(inventory, newInventoryItem(live))
+	}
+
+	return inventory, diags
+}
@@ -0,0 +1,73 @@
+package octal
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/go-cty/cty"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	k8sschema "k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestInventoryRoundTrip(t *testing.T) {
+	inventory := []inventoryItem{
+		{Version: "v1", Kind: "ServiceAccount", Namespace: "cert-manager", Name: "cert-manager", UID: "1"},
+		{Group: "rbac.authorization.k8s.io", Version: "v1", Kind: "ClusterRole", Name: "cert-manager-controller-issuers", UID: "2"},
+	}
+
+	if expanded := expandInventory(flattenInventory(inventory)); !reflect.DeepEqual(expanded, inventory) {
+		t.Fatalf("expected %#v, got %#v", inventory, expanded)
+	}
+}
+
+func TestReadInventory(t *testing.T) {
+	serviceAccount := testObject(testServiceAccountKind, "cert-manager", "cert-manager", nil)
+	serviceAccount.SetUID("recreated")
+	client := newTestApiClient(
+		serviceAccount,
+		testObject(testClusterRoleKind, "", "cert-manager-controller-issuers", nil),
+		testObject(testClusterRoleKind, "", "cert-manager-controller-certificates", nil),
+	)
+	client.dynamicClient.(*fake.FakeDynamicClient).PrependReactor("get", "clusterroles", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		if action.(k8stesting.GetAction).GetName() != "forbidden" {
+			return false, nil, nil
+		}
+		return true, nil, apierrors.NewForbidden(k8sschema.GroupResource{Group: "rbac.authorization.k8s.io", Resource: "clusterroles"}, "forbidden", errors.New("no access"))
+	})
+
+	inventory, live, diags := readInventory(context.Background(), client, []inventoryItem{
+		{Group: "rbac.authorization.k8s.io", Version: "v1", Kind: "ClusterRole", Name: "forbidden", UID: "3"},
+		{Version: "v1", Kind: "ServiceAccount", Namespace: "cert-manager", Name: "cert-manager", UID: "original"},
+		{Group: "rbac.authorization.k8s.io", Version: "v1", Kind: "ClusterRole", Name: "cert-manager-controller-issuers"},
+		{Group: "rbac.authorization.k8s.io", Version: "v1", Kind: "ClusterRole", Name: "cert-manager-controller-certificates"},
+		{Group: "rbac.authorization.k8s.io", Version: "v1", Kind: "ClusterRole", Name: "deleted"},
+	})
+	if len(inventory) != 4 || len(live) != 3 {
+		t.Fatalf("expected the deleted ClusterRole to be dropped and the forbidden one kept, got %#v", inventory)
+	}
+	if inventory[0].UID != "3" {
+		t.Errorf("expected the ClusterRole that couldn't be read to keep its uid, got %q", inventory[0].UID)
+	}
+	if inventory[1].UID != "recreated" {
+		t.Errorf("expected the uid of the recreated ServiceAccount, got %q", inventory[1].UID)
+	}
+
+	if len(diags) != 1 {
+		t.Fatalf("expected a single diagnostic, got %#v", diags)
+	}
+	if diags[0].Summary != "Failed to read ClusterRole forbidden" {
+		t.Errorf("expected the diagnostic to name the ClusterRole, got %q", diags[0].Summary)
+	}
+	if !strings.Contains(diags[0].Detail, "Forbidden (HTTP 403)") {
+		t.Errorf("expected the diagnostic to give the API server's reason, got %q", diags[0].Detail)
+	}
+	if !diags[0].AttributePath.Equals(cty.GetAttrPath("inventory")) {
+		t.Errorf("expected the diagnostic to point at the inventory, got %#v", diags[0].AttributePath)
+	}
+}
@@ -4,11 +4,18 @@ import (
 	"context"
 	"fmt"
 	"github.com/hashicorp/terraform-plugin-log/tflog"
-	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
-func flattenMetadata(component string, objectMeta *metav1.ObjectMeta) []map[string]interface{} {
+// resourceSettings is what customizing a bundle reads from the resource. It's provided by
+// *schema.ResourceData, and at plan time by *schema.ResourceDiff.
+type resourceSettings interface {
+	Id() string
+	Get(key string) interface{}
+	GetOk(key string) (interface{}, bool)
+}
+
+func flattenMetadata(component string, objectMeta metav1.Object) []map[string]interface{} {
 	flatMetadata := make([]map[string]interface{}, 1)
 	flatMetadata[0] = map[string]interface{}{
 		"uid":              fmt.Sprintf("%s", objectMeta.GetUID()),
@@ -40,34 +47,31 @@ func flattenMetadata(component string, objectMeta *metav1.ObjectMeta) []map[stri
 
 // This applied the updates provided by the Terraform resource to the base Namespace Object
 // Adds the labels and annotations defined by the Terraform resource.
-func updateMetadata(ctx context.Context, componentName string, namespaced bool, metaData *metav1.ObjectMeta, d *schema.ResourceData) {
+func updateMetadata(ctx context.Context, instanceLabel string, version string, componentName string, namespaced bool, metaData metav1.Object, d resourceSettings) {
 
 	componentConfig := map[string]interface{}{
-		"namespace":   d.Get("name"),
 		"labels":      map[string]interface{}{},
 		"annotations": map[string]interface{}{},
 	}
 
 	// Get the component's configuration from the resource block.
-	component, exists := d.GetOk(componentName)
-	if exists {
-		if component != nil && len(component.([]interface{})) > 0 {
-			componentConfig = component.([]interface{})[0].(map[string]interface{})
-		}
+	if component, ok := d.Get(componentName).([]interface{}); ok && len(component) > 0 && component[0] != nil {
+		componentConfig = component[0].(map[string]interface{})
 	}
 
 	/*******************************\
 	** MetaData Object Namespace   **
 	\*******************************/
 
-	// Set the namespace of the component, if the component is a namespaced resource
-	if namespaced {
-		namespaceName, namespaceNameExists := d.GetOk("name")
+	// Set the namespace of the component, if the component is a namespaced resource.
+	// Manifests that name their own namespace, like the leader election roles in
+	// kube-system, keep it.
+	if namespaced && metaData.GetNamespace() == "" {
+		namespaceName, namespaceNameExists := d.GetOk("namespace")
 		if !namespaceNameExists {
-			tflog.Info(ctx, "[INFO?] The key 'name' couldn't be found?")
+			tflog.Info(ctx, "[INFO?] The key 'namespace' couldn't be found?")
 		}
 		metaData.SetNamespace(namespaceName.(string))
-
 	}
 
 	/*******************************\
@@ -79,23 +83,29 @@ func updateMetadata(ctx context.Context, componentName string, namespaced bool,
 	resourceName := d.Get("name").(string)
 	var componentFullName string
 	if componentName == "namespace" {
-		componentFullName = resourceName
+		componentFullName = d.Get("namespace").(string)
 	} else {
 		componentFullName = fmt.Sprintf("%s-%s", resourceName, componentName)
 	}
 
-	// Set the name of the component
-	metaData.SetName(componentFullName)
+	// Set the name of the component. A bundle usually ships several objects of the same
+	// kind per component, so names given by the manifest are kept.
+	if componentName == "namespace" || metaData.GetName() == "" {
+		metaData.SetName(componentFullName)
+	}
 
 	/*******************************\
 	** MetaData Object Labels      **
 	\*******************************/
 
 	// Get the labels specified in the resource block
-	componentConfigLabels := componentConfig["labels"].(map[string]interface{})
+	componentConfigLabels, _ := componentConfig["labels"].(map[string]interface{})
 
 	// Create an object that will hold the metadata objects labels
 	componentLabels := map[string]string{}
+	for key, value := range metaData.GetLabels() {
+		componentLabels[key] = value
+	}
 
 	// Get the labels from the component config
 	if len(componentConfigLabels) > 0 {
@@ -105,9 +115,9 @@ func updateMetadata(ctx context.Context, componentName string, namespaced bool,
 	}
 
 	// Add the component labels that get added to everything
-	componentLabels["project-octal.io/cert-manager-schema"] = d.Id()
+	componentLabels[instanceLabel] = d.Id()
 	componentLabels["app.kubernetes.io/instance"] = d.Id()
-	componentLabels["app.kubernetes.io/version"] = d.Get("version").(string)
+	componentLabels["app.kubernetes.io/version"] = version
 	componentLabels["app.kubernetes.io/name"] = componentFullName
 	componentLabels["app.kubernetes.io/component"] = componentName
 	componentLabels["app.kubernetes.io/part-of"] = resourceName
@@ -122,10 +132,13 @@ func updateMetadata(ctx context.Context, componentName string, namespaced bool,
 	\*******************************/
 
 	// Get the annotations specified in the resource block
-	componentConfigAnnotations := componentConfig["annotations"].(map[string]interface{})
+	componentConfigAnnotations, _ := componentConfig["annotations"].(map[string]interface{})
 
 	// Create an object that will hold the metadata objects annotations
 	componentAnnotations := map[string]string{}
+	for key, value := range metaData.GetAnnotations() {
+		componentAnnotations[key] = value
+	}
 
 	// Get the annotations from the component config
 	if len(componentConfigAnnotations) > 0 {
@@ -135,5 +148,5 @@ func updateMetadata(ctx context.Context, componentName string, namespaced bool,
 	}
 
 	// Apply the annotation update to the metadata object
-	metaData.SetAnnotations(metaData.Annotations)
+	metaData.SetAnnotations(componentAnnotations)
 }
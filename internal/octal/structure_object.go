@@ -2,68 +2,171 @@ package octal
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 
-	"github.com/hashicorp/terraform-plugin-log/tflog"
-	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
-	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
-	Appsv1 "k8s.io/api/apps/v1"
-	"k8s.io/apiextensions-apiserver/pkg/client/clientset/clientset"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	k8sschema "k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/dynamic"
 )
 
-func createKubernetesObject(ctx context.Context, meta interface{}, d *schema.ResourceData, kind string, namespace string, deployment runtime.Object, opts *v1.CreateOptions) (result runtime.Object, err error) { // (result rest.Result) {
+// objectMapping resolves the API resource that serves a kind. Kinds discovery doesn't know
+// yet, like those of a CRD applied moments ago, get a second chance after the discovery
+// cache is dropped.
+func objectMapping(meta interface{}, gvk k8sschema.GroupVersionKind) (*apimeta.RESTMapping, error) {
+	mapper, err := meta.(*apiClient).RESTMapper()
+	if err != nil {
+		return nil, err
+	}
+
+	mapping, err := mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+	if apimeta.IsNoMatchError(err) {
+		if resettable, ok := mapper.(apimeta.ResettableRESTMapper); ok {
+			resettable.Reset()
+			mapping, err = mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to find the API resource for %s: %w", gvk, err)
+	}
+	return mapping, nil
+}
+
+// isNoMatch reports whether the error says the cluster doesn't serve a kind.
+func isNoMatch(err error) bool {
+	var noKindMatch *apimeta.NoKindMatchError
+	var noResourceMatch *apimeta.NoResourceMatchError
+	return errors.As(err, &noKindMatch) || errors.As(err, &noResourceMatch)
+}
+
+// resetRESTMapper drops the discovery cache, so kinds added since it was filled resolve.
+func resetRESTMapper(meta interface{}) {
+	mapper, err := meta.(*apiClient).RESTMapper()
+	if err != nil {
+		return
+	}
+	if resettable, ok := mapper.(apimeta.ResettableRESTMapper); ok {
+		resettable.Reset()
+	}
+}
+
+// isNamespaced reports whether objects served by the mapping live in a namespace.
+func isNamespaced(mapping *apimeta.RESTMapping) bool {
+	return mapping.Scope.Name() == apimeta.RESTScopeNameNamespace
+}
+
+// resourceInterface returns the dynamic client for the mapping's resource, scoped to the
+// namespace when the resource is namespaced.
+func resourceInterface(meta interface{}, mapping *apimeta.RESTMapping, namespace string) (dynamic.ResourceInterface, error) {
+	dynamicClient, err := meta.(*apiClient).DynamicClient()
+	if err != nil {
+		return nil, err
+	}
+
+	if isNamespaced(mapping) {
+		return dynamicClient.Resource(mapping.Resource).Namespace(namespace), nil
+	}
+	return dynamicClient.Resource(mapping.Resource), nil
+}
 
-	tflog.Info(ctx, fmt.Sprintf("The Deployment: %s", "idk"))
-	tflog.Info(ctx, fmt.Sprintf("The namespace: %s", namespace))
-	tflog.Info(ctx, fmt.Sprintf("The kind: %s", deployment.GetObjectKind()))
+// objectClient returns the dynamic client that serves the object.
+func objectClient(meta interface{}, object *unstructured.Unstructured) (dynamic.ResourceInterface, error) {
+	mapping, err := objectMapping(meta, object.GroupVersionKind())
+	if err != nil {
+		return nil, err
+	}
+	return resourceInterface(meta, mapping, object.GetNamespace())
+}
+
+// getObject reads the live copy of the object.
+func getObject(ctx context.Context, meta interface{}, object *unstructured.Unstructured) (*unstructured.Unstructured, error) {
+	client, err := objectClient(meta, object)
+	if err != nil {
+		return nil, err
+	}
+
+	var live *unstructured.Unstructured
+	err = retryTransient(ctx, "reading "+object.GetKind()+" "+objectName(object), func() error {
+		live, err = client.Get(ctx, object.GetName(), metav1.GetOptions{})
+		return err
+	})
+	return live, err
+}
+
+// applyObject sends the object to the API server as a server-side apply under the
+// provider's field manager. The API server merges it with whatever other managers own,
+// and creates the object if it doesn't exist yet. takeOwnership forces the apply even when
+// the provider doesn't force conflicts.
+func applyObject(ctx context.Context, meta interface{}, object *unstructured.Unstructured, takeOwnership bool) (*unstructured.Unstructured, error) {
+	client, err := objectClient(meta, object)
+	if err != nil {
+		return nil, err
+	}
 
-	client, clientErr := clientset.NewForConfig(meta.(*apiClient).config)
-	err = client.RESTClient().Post().
-		Namespace(namespace).
-		Resource("deployments").
-		Body(deployment).
-		Do(ctx).Into(result)
+	body, err := json.Marshal(object)
+	if err != nil {
+		return nil, err
+	}
 
-	if clientErr != nil {
-		tflog.Error(ctx, fmt.Sprintf("Failed to create Object: %s", err.Error()))
+	fieldManager := meta.(*apiClient).fieldManager
+	if fieldManager == "" {
+		fieldManager = defaultFieldManager
 	}
+	force := meta.(*apiClient).forceConflicts || takeOwnership
 
-	return result, err
+	// The apply configuration carries no resourceVersion, so a write conflict only needs the
+	// same apply sent again, against whatever the object has become since.
+	var applied *unstructured.Unstructured
+	err = retryTransient(ctx, "applying "+object.GetKind()+" "+objectName(object), func() error {
+		applied, err = client.Patch(ctx, object.GetName(), types.ApplyPatchType, body, metav1.PatchOptions{
+			FieldManager: fieldManager,
+			Force:        &force,
+		})
+		return err
+	})
+	return applied, err
 }
 
-func createObject(ctx context.Context, meta interface{}, d *schema.ResourceData, component string, defaultDeployment Appsv1.Deployment) diag.Diagnostics {
-	var diags diag.Diagnostics
-
-	namespace := d.Get("name").(string)
-
-	/*******************************\
-	** Update Manifest MetaData    **
-	\*******************************/
-	// This applied the updates provided by the Terraform resource to the base Namespace Object
-	updateMetadata(ctx, component, true, &defaultDeployment.ObjectMeta, d)
-
-	/*******************************\
-	** Create Kubernetes Object    **
-	\*******************************/
-	// Here we create the object using a template object customized by the resource inputs.
-	tflog.Info(ctx, fmt.Sprintf("[INFO] Creating new %s: %#v", component, defaultDeployment.Name))
-	// Get the K8s client
-	client := meta.(*apiClient).clientset
-	_, err := client.AppsV1().Deployments(namespace).Create(ctx, &defaultDeployment, metav1.CreateOptions{})
+// deleteObject deletes the object. Objects that are already gone aren't an error.
+func deleteObject(ctx context.Context, meta interface{}, object *unstructured.Unstructured) error {
+	client, err := objectClient(meta, object)
 	if err != nil {
-		tflog.Error(ctx, err.Error())
-		return diags
+		return err
 	}
-	tflog.Info(ctx, "[INFO] created a resource")
 
-	/*******************************\
-	** Read New Object Back        **
-	\*******************************/
-	// Here we appear to read back the namespace state to make sure it's consistent?
-	readDeployment(ctx, d, meta, component)
+	err = retryTransient(ctx, "deleting "+object.GetKind()+" "+objectName(object), func() error {
+		return client.Delete(ctx, object.GetName(), metav1.DeleteOptions{})
+	})
+	if apierrors.IsNotFound(err) {
+		return nil
+	}
+	return err
+}
 
-	return diags
+// listObjects lists the objects of a kind that match the list options. An empty namespace
+// lists across all namespaces.
+func listObjects(ctx context.Context, meta interface{}, gvk k8sschema.GroupVersionKind, namespace string, options metav1.ListOptions) ([]unstructured.Unstructured, error) {
+	mapping, err := objectMapping(meta, gvk)
+	if err != nil {
+		return nil, err
+	}
+	client, err := resourceInterface(meta, mapping, namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	var list *unstructured.UnstructuredList
+	err = retryTransient(ctx, "listing "+gvk.Kind+" objects", func() error {
+		list, err = client.List(ctx, options)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return list.Items, nil
 }
@@ -0,0 +1,137 @@
+package octal
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	k8sschema "k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic/fake"
+	restclient "k8s.io/client-go/rest"
+)
+
+var (
+	testServiceAccountKind = k8sschema.GroupVersionKind{Version: "v1", Kind: "ServiceAccount"}
+	testClusterRoleKind    = k8sschema.GroupVersionKind{Group: "rbac.authorization.k8s.io", Version: "v1", Kind: "ClusterRole"}
+	testDeploymentKind     = k8sschema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"}
+	testNamespaceKind      = k8sschema.GroupVersionKind{Version: "v1", Kind: "Namespace"}
+	testSecretKind         = k8sschema.GroupVersionKind{Version: "v1", Kind: "Secret"}
+)
+
+// newTestApiClient returns a client backed by a fake dynamic client that knows a few
+// namespaced and cluster-scoped kinds.
+func newTestApiClient(objects ...runtime.Object) *apiClient {
+	scopes := map[k8sschema.GroupVersionKind]apimeta.RESTScope{
+		testServiceAccountKind: apimeta.RESTScopeNamespace,
+		testClusterRoleKind:    apimeta.RESTScopeRoot,
+		testDeploymentKind:     apimeta.RESTScopeNamespace,
+		testNamespaceKind:      apimeta.RESTScopeRoot,
+		testSecretKind:         apimeta.RESTScopeNamespace,
+	}
+	mapper := apimeta.NewDefaultRESTMapper(nil)
+	// Every kind can be listed, whether or not there are objects of it.
+	listKinds := map[k8sschema.GroupVersionResource]string{}
+	for gvk, scope := range scopes {
+		mapper.Add(gvk, scope)
+		resource, _ := apimeta.UnsafeGuessKindToResource(gvk)
+		listKinds[resource] = gvk.Kind + "List"
+	}
+
+	client := newApiClient(&restclient.Config{Host: "https://cluster.example.com"}, providerSettings{})
+	client.dynamicClientOnce.Do(func() {
+		client.dynamicClient = fake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), listKinds, objects...)
+	})
+	client.restMapperOnce.Do(func() {
+		client.restMapper = mapper
+	})
+	return client
+}
+
+func testObject(gvk k8sschema.GroupVersionKind, namespace, name string, labels map[string]string) *unstructured.Unstructured {
+	object := &unstructured.Unstructured{}
+	object.SetGroupVersionKind(gvk)
+	object.SetNamespace(namespace)
+	object.SetName(name)
+	object.SetLabels(labels)
+	return object
+}
+
+func TestPrepareObjectScope(t *testing.T) {
+	d := schema.TestResourceDataRaw(t, resourceOctalCertManager().Schema, map[string]interface{}{
+		"namespace": "cert-manager",
+	})
+	d.SetId("instance")
+	client := newTestApiClient()
+
+	serviceAccount := bundleObject{bundle: certManagerBundle(), component: "controller", object: testObject(testServiceAccountKind, "", "cert-manager", nil)}
+	if err := prepareObject(context.Background(), d, client, serviceAccount); err != nil {
+		t.Fatal(err)
+	}
+	if serviceAccount.object.GetNamespace() != "cert-manager" {
+		t.Errorf("expected the ServiceAccount in the resource's namespace, got %q", serviceAccount.object.GetNamespace())
+	}
+	if serviceAccount.object.GetLabels()["project-octal.io/cert-manager-schema"] != "instance" {
+		t.Errorf("expected the instance label, got %v", serviceAccount.object.GetLabels())
+	}
+
+	clusterRole := bundleObject{bundle: certManagerBundle(), component: "controller", object: testObject(testClusterRoleKind, "stray", "cert-manager-controller-issuers", nil)}
+	if err := prepareObject(context.Background(), d, client, clusterRole); err != nil {
+		t.Fatal(err)
+	}
+	if clusterRole.object.GetNamespace() != "" {
+		t.Errorf("expected the ClusterRole without a namespace, got %q", clusterRole.object.GetNamespace())
+	}
+
+	unknown := bundleObject{bundle: certManagerBundle(), component: "controller", object: testObject(k8sschema.GroupVersionKind{Group: "example.com", Version: "v1", Kind: "Widget"}, "", "widget", nil)}
+	if err := prepareObject(context.Background(), d, client, unknown); err == nil {
+		t.Error("expected an error for a kind the cluster doesn't serve")
+	}
+}
+
+func TestObjectEngine(t *testing.T) {
+	ctx := context.Background()
+	labels := map[string]string{
+		"project-octal.io/cert-manager-schema": "instance",
+		"app.kubernetes.io/instance":           "instance",
+	}
+	client := newTestApiClient(
+		testObject(testServiceAccountKind, "cert-manager", "cert-manager", labels),
+		testObject(testServiceAccountKind, "cert-manager", "unrelated", nil),
+		testObject(testClusterRoleKind, "", "cert-manager-controller-issuers", labels),
+		testObject(testClusterRoleKind, "", "cert-manager-controller-certificates", labels),
+	)
+
+	clusterRoles, err := listObjects(ctx, client, testClusterRoleKind, "", certManagerBundle().listOptions("instance"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(clusterRoles) != 2 {
+		t.Errorf("expected 2 ClusterRoles, got %d", len(clusterRoles))
+	}
+
+	serviceAccounts, err := listObjects(ctx, client, testServiceAccountKind, "cert-manager", certManagerBundle().listOptions("instance"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(serviceAccounts) != 1 {
+		t.Errorf("expected 1 ServiceAccount, got %d", len(serviceAccounts))
+	}
+
+	serviceAccount := testObject(testServiceAccountKind, "cert-manager", "cert-manager", nil)
+	if _, err := getObject(ctx, client, serviceAccount); err != nil {
+		t.Fatal(err)
+	}
+	if err := deleteObject(ctx, client, serviceAccount); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := getObject(ctx, client, serviceAccount); !apierrors.IsNotFound(err) {
+		t.Fatalf("expected the ServiceAccount to be gone, got %v", err)
+	}
+	if err := deleteObject(ctx, client, serviceAccount); err != nil {
+		t.Fatalf("deleting a missing object should succeed, got %v", err)
+	}
+}
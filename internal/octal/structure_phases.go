@@ -0,0 +1,106 @@
+package octal
+
+import (
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// applyPhase orders the objects of a bundle so everything an object depends on exists
+// before it's applied. Destroy walks the phases backwards.
+type applyPhase int
+
+const (
+	phaseCustomResourceDefinitions applyPhase = iota
+	phaseNamespaces
+	phaseServiceAccounts
+	phaseRoles
+	phaseRoleBindings
+	phaseConfiguration
+	phaseServices
+	phaseWorkloads
+	phaseWebhooks
+	// phaseOther holds everything else, including custom resources, which need their CRD
+	// established and are usually validated by a webhook.
+	phaseOther
+
+	phaseCount
+)
+
+var phaseNames = [phaseCount]string{
+	"custom resource definitions",
+	"namespaces",
+	"service accounts",
+	"roles",
+	"role bindings",
+	"config maps and secrets",
+	"services",
+	"workloads",
+	"admission webhooks",
+	"other objects",
+}
+
+func (p applyPhase) String() string {
+	return phaseNames[p]
+}
+
+// objectPhase returns the phase an object is applied in.
+func objectPhase(object *unstructured.Unstructured) applyPhase {
+	gvk := object.GroupVersionKind()
+
+	switch gvk.Group {
+	case "apiextensions.k8s.io":
+		if gvk.Kind == "CustomResourceDefinition" {
+			return phaseCustomResourceDefinitions
+		}
+	case "":
+		switch gvk.Kind {
+		case "Namespace":
+			return phaseNamespaces
+		case "ServiceAccount":
+			return phaseServiceAccounts
+		case "ConfigMap", "Secret":
+			return phaseConfiguration
+		case "Service":
+			return phaseServices
+		case "Pod", "ReplicationController":
+			return phaseWorkloads
+		}
+	case "rbac.authorization.k8s.io":
+		switch gvk.Kind {
+		case "Role", "ClusterRole":
+			return phaseRoles
+		case "RoleBinding", "ClusterRoleBinding":
+			return phaseRoleBindings
+		}
+	case "apps":
+		switch gvk.Kind {
+		case "Deployment", "StatefulSet", "DaemonSet", "ReplicaSet":
+			return phaseWorkloads
+		}
+	case "batch":
+		switch gvk.Kind {
+		case "Job", "CronJob":
+			return phaseWorkloads
+		}
+	case "admissionregistration.k8s.io":
+		switch gvk.Kind {
+		case "MutatingWebhookConfiguration", "ValidatingWebhookConfiguration":
+			return phaseWebhooks
+		}
+	case "apiregistration.k8s.io":
+		if gvk.Kind == "APIService" {
+			return phaseWebhooks
+		}
+	}
+
+	return phaseOther
+}
+
+// groupByPhase splits the objects into their phases, keeping the bundle's order within each.
+func groupByPhase(objects []bundleObject) [phaseCount][]bundleObject {
+	var phases [phaseCount][]bundleObject
+	for _, object := range objects {
+		phase := objectPhase(object.object)
+		phases[phase] = append(phases[phase], object)
+	}
+	return phases
+}
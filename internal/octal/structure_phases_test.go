@@ -0,0 +1,81 @@
+package octal
+
+import (
+	"context"
+	"testing"
+
+	k8sschema "k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestGroupByPhase(t *testing.T) {
+	kinds := []k8sschema.GroupVersionKind{
+		{Group: "admissionregistration.k8s.io", Version: "v1", Kind: "ValidatingWebhookConfiguration"},
+		{Group: "apps", Version: "v1", Kind: "Deployment"},
+		{Group: "cert-manager.io", Version: "v1", Kind: "ClusterIssuer"},
+		{Version: "v1", Kind: "Service"},
+		{Group: "rbac.authorization.k8s.io", Version: "v1", Kind: "ClusterRoleBinding"},
+		{Group: "rbac.authorization.k8s.io", Version: "v1", Kind: "ClusterRole"},
+		{Version: "v1", Kind: "ServiceAccount"},
+		{Version: "v1", Kind: "Namespace"},
+		{Group: "apiextensions.k8s.io", Version: "v1", Kind: "CustomResourceDefinition"},
+	}
+
+	var objects []bundleObject
+	for _, kind := range kinds {
+		objects = append(objects, bundleObject{component: "controller", object: testObject(kind, "", "object", nil)})
+	}
+
+	var order []string
+	for _, phase := range groupByPhase(objects) {
+		for _, object := range phase {
+			order = append(order, object.object.GetKind())
+		}
+	}
+
+	expected := []string{
+		"CustomResourceDefinition",
+		"Namespace",
+		"ServiceAccount",
+		"ClusterRole",
+		"ClusterRoleBinding",
+		"Service",
+		"Deployment",
+		"ValidatingWebhookConfiguration",
+		"ClusterIssuer",
+	}
+	if len(order) != len(expected) {
+		t.Fatalf("expected %v, got %v", expected, order)
+	}
+	for i := range expected {
+		if order[i] != expected[i] {
+			t.Fatalf("expected %v, got %v", expected, order)
+		}
+	}
+}
+
+func TestDeleteObjectsReverseOrder(t *testing.T) {
+	client := newTestApiClient(
+		testObject(testServiceAccountKind, "cert-manager", "cert-manager", nil),
+		testObject(testClusterRoleKind, "", "cert-manager-controller-issuers", nil),
+	)
+
+	diags := deleteObjects(context.Background(), client, inventoryObjects([]inventoryItem{
+		{Version: "v1", Kind: "ServiceAccount", Namespace: "cert-manager", Name: "cert-manager"},
+		{Group: "rbac.authorization.k8s.io", Version: "v1", Kind: "ClusterRole", Name: "cert-manager-controller-issuers"},
+	}))
+	if diags.HasError() {
+		t.Fatalf("unexpected diagnostics: %#v", diags)
+	}
+
+	var deleted []string
+	for _, action := range client.dynamicClient.(*fake.FakeDynamicClient).Actions() {
+		if action, ok := action.(k8stesting.DeleteAction); ok {
+			deleted = append(deleted, action.GetResource().Resource)
+		}
+	}
+	if len(deleted) != 2 || deleted[0] != "clusterroles" || deleted[1] != "serviceaccounts" {
+		t.Fatalf("expected the ClusterRole to be deleted before the ServiceAccount, got %v", deleted)
+	}
+}
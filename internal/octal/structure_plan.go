@@ -0,0 +1,242 @@
+package octal
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// hashSettings hides the resource id from rendering. A new resource only gets its id
+// when it's created, so leaving it out lets the plan and the apply hash the same bundle.
+type hashSettings struct {
+	resourceSettings
+}
+
+func (hashSettings) Id() string {
+	return ""
+}
+
+// renderedHash hashes the rendered bundle, so any change to what would be applied shows
+// up as a change to `rendered_hash`.
+func renderedHash(ctx context.Context, bundle *bundleDefinition, d resourceSettings, meta interface{}) (string, error) {
+	objects, err := renderBundle(ctx, bundle, hashSettings{d}, meta)
+	if err != nil {
+		return "", err
+	}
+
+	rendered := make([]string, len(objects))
+	for index, object := range objects {
+		raw, err := json.Marshal(object.object)
+		if err != nil {
+			return "", err
+		}
+		rendered[index] = string(raw)
+	}
+	sort.Strings(rendered)
+
+	raw, err := json.Marshal(rendered)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%x", sha256.Sum256(raw)), nil
+}
+
+// priorSettings reads the settings the installation was last applied with, so the bundle
+// can be rendered the way it was.
+type priorSettings struct {
+	*schema.ResourceDiff
+}
+
+func (s priorSettings) Get(key string) interface{} {
+	prior, _ := s.ResourceDiff.GetChange(key)
+	return prior
+}
+
+func (s priorSettings) GetOk(key string) (interface{}, bool) {
+	prior := s.Get(key)
+	return prior, !isEmptyValue(prior) && !reflect.ValueOf(prior).IsZero()
+}
+
+// planChanges compares the rendered bundle with the inventory, the bundle as it was last
+// rendered and the live objects, and describes what applying it would do to each object:
+// create or adopt it, change the fields whose rendering changed, or delete it. Fields are
+// only compared between the two renderings, whatever `drift_mode` is; drift on the cluster
+// is reported through `drift` instead.
+func planChanges(ctx context.Context, d resourceSettings, meta interface{}, prior []bundleObject, objects []bundleObject) ([]string, error) {
+	var changes []string
+
+	inventory := map[string]bool{}
+	for _, item := range expandInventory(d.Get("inventory").([]interface{})) {
+		inventory[item.key()] = true
+	}
+
+	previous := map[string]*unstructured.Unstructured{}
+	for _, object := range prior {
+		previous[newInventoryItem(object.object).key()] = object.object
+	}
+
+	var rendered []inventoryItem
+	for _, object := range objects {
+		item := newInventoryItem(object.object)
+		rendered = append(rendered, item)
+
+		if !inventory[item.key()] {
+			if d.Id() == "" && d.Get("adopt_existing").(bool) {
+				_, err := getObject(ctx, meta, object.object)
+				if err == nil {
+					changes = append(changes, fmt.Sprintf("adopt %s %s", item.Kind, objectName(object.object)))
+					continue
+				}
+				if !apierrors.IsNotFound(err) && !isNoMatch(err) {
+					return nil, err
+				}
+			}
+			changes = append(changes, fmt.Sprintf("create %s %s", item.Kind, objectName(object.object)))
+			continue
+		}
+
+		_, err := getObject(ctx, meta, object.object)
+		if apierrors.IsNotFound(err) {
+			changes = append(changes, fmt.Sprintf("create %s %s", item.Kind, objectName(object.object)))
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if before, ok := previous[item.key()]; ok {
+			for _, field := range renderingChanges(before, object.object) {
+				changes = append(changes, "update "+field)
+			}
+		}
+	}
+
+	if d.Get("prune").(bool) {
+		for _, description := range describeInventory(inventoryDifference(expandInventory(d.Get("inventory").([]interface{})), rendered)) {
+			changes = append(changes, "delete "+description)
+		}
+	}
+
+	return changes, nil
+}
+
+// renderingChanges lists the fields that differ between two renderings of an object: those
+// the new rendering changes as well as those it no longer sets.
+func renderingChanges(before *unstructured.Unstructured, after *unstructured.Unstructured) []string {
+	var fields []string
+	seen := map[string]bool{}
+	for _, field := range append(objectDrift(after, before), objectDrift(before, after)...) {
+		if !seen[field] {
+			seen[field] = true
+			fields = append(fields, field)
+		}
+	}
+	sort.Strings(fields)
+	return fields
+}
+
+// connectedBy lists the blocks the client of an installation depends on. While anything in
+// them is unknown, e.g. the host of a cluster created in the same run, an empty value would
+// make the plan fall back to the provider's cluster and ask the wrong one.
+var connectedBy = []string{"cluster", "impersonate"}
+
+// planKnown reports whether everything the plan renders and asks the cluster with is known.
+func planKnown(bundle *bundleDefinition, d *schema.ResourceDiff) bool {
+	keys := bundle.renderedBy()
+	resourceSchema := bundleSchema(bundle)
+	for _, block := range connectedBy {
+		keys = append(keys, nestedKeys(block, resourceSchema[block])...)
+	}
+
+	for _, key := range keys {
+		if !d.NewValueKnown(key) {
+			return false
+		}
+	}
+	return true
+}
+
+// nestedKeys lists the key of an attribute and, for a block of at most one item, the keys
+// of everything in it. The block itself is known as soon as it's in the configuration,
+// whatever its attributes are.
+func nestedKeys(key string, attribute *schema.Schema) []string {
+	keys := []string{key}
+	block, ok := attribute.Elem.(*schema.Resource)
+	if !ok || attribute.MaxItems != 1 {
+		return keys
+	}
+	for name, nested := range block.Schema {
+		keys = append(keys, nestedKeys(fmt.Sprintf("%s.0.%s", key, name), nested)...)
+	}
+	return keys
+}
+
+// customizeDiffRender renders the bundle at plan time. The plan then shows whether the
+// rendered bundle changed, through `rendered_hash`, and which objects the apply is going to
+// create, change or delete, through `changes`.
+func customizeDiffRender(bundle *bundleDefinition) schema.CustomizeDiffFunc {
+	return func(ctx context.Context, d *schema.ResourceDiff, meta interface{}) error {
+		if !planKnown(bundle, d) {
+			return setRenderUnknown(d)
+		}
+
+		client, err := resourceClient(d, meta)
+		if err != nil {
+			return err
+		}
+
+		hash, err := renderedHash(ctx, bundle, d, client)
+		if errors.Is(err, errConfigUnknown) {
+			// Without a cluster to ask, the scope of each kind isn't known. Installations that
+			// exist already keep their values rather than planning an update each time.
+			if d.Id() != "" {
+				return nil
+			}
+			return setRenderUnknown(d)
+		}
+		if err != nil {
+			return err
+		}
+		if hash != d.Get("rendered_hash").(string) {
+			if err := d.SetNew("rendered_hash", hash); err != nil {
+				return err
+			}
+		}
+
+		objects, err := renderBundle(ctx, bundle, d, client)
+		if err != nil {
+			return err
+		}
+		var prior []bundleObject
+		if d.Id() != "" {
+			prior, err = renderBundle(ctx, bundle, priorSettings{d}, client)
+			if err != nil {
+				return err
+			}
+		}
+		changes, err := planChanges(ctx, d, client, prior, objects)
+		if err != nil {
+			return err
+		}
+		// `changes` keeps what the latest apply did until there's something new to do, so it
+		// doesn't keep planning updates of its own.
+		if len(changes) > 0 {
+			return d.SetNew("changes", changes)
+		}
+		return nil
+	}
+}
+
+func setRenderUnknown(d *schema.ResourceDiff) error {
+	if err := d.SetNewComputed("rendered_hash"); err != nil {
+		return err
+	}
+	return d.SetNewComputed("changes")
+}
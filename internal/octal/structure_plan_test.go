@@ -0,0 +1,87 @@
+package octal
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
+)
+
+func TestPlanChanges(t *testing.T) {
+	// The changes come from the bundle alone, whatever happens to drift.
+	expected := []string{
+		"update ServiceAccount cert-manager/cert-manager: .metadata.annotations.note",
+		"update ServiceAccount cert-manager/cert-manager: .metadata.labels.app",
+		"create Deployment cert-manager/cert-manager",
+		"delete ClusterRole cert-manager-edit",
+	}
+	for _, mode := range []string{driftModeCorrect, driftModeWarn, driftModeIgnore} {
+		t.Run(mode, func(t *testing.T) {
+			testPlanChanges(t, mode, expected)
+		})
+	}
+}
+
+func testPlanChanges(t *testing.T, driftMode string, expected []string) {
+	d := schema.TestResourceDataRaw(t, resourceOctalCertManager().Schema, map[string]interface{}{
+		"namespace":  "cert-manager",
+		"drift_mode": driftMode,
+	})
+	d.SetId("instance")
+	d.Set("inventory", flattenInventory([]inventoryItem{
+		{Version: "v1", Kind: "ServiceAccount", Namespace: "cert-manager", Name: "cert-manager"},
+		{Group: "rbac.authorization.k8s.io", Version: "v1", Kind: "ClusterRole", Name: "cert-manager-edit"},
+	}))
+
+	// The live ServiceAccount drifted from both renderings.
+	client := newTestApiClient(
+		testObject(testServiceAccountKind, "cert-manager", "cert-manager", map[string]string{"app": "edited"}),
+		testObject(testClusterRoleKind, "", "cert-manager-edit", nil),
+	)
+
+	before := testObject(testServiceAccountKind, "cert-manager", "cert-manager", map[string]string{"app": "cert-manager"})
+	before.SetAnnotations(map[string]string{"kept": "true", "note": "dropped by the new rendering"})
+	after := testObject(testServiceAccountKind, "cert-manager", "cert-manager", map[string]string{"app": "controller"})
+	after.SetAnnotations(map[string]string{"kept": "true"})
+	prior := []bundleObject{
+		{component: "controller", object: before},
+		{component: "controller", object: testObject(testClusterRoleKind, "", "cert-manager-edit", nil)},
+	}
+
+	changes, err := planChanges(context.Background(), d, client, prior, []bundleObject{
+		{component: "controller", object: after},
+		{component: "controller", object: testObject(testDeploymentKind, "cert-manager", "cert-manager", nil)},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(changes, expected) {
+		t.Fatalf("expected %v, got %v", expected, changes)
+	}
+}
+
+func TestCustomizeDiffRenderUnknownCluster(t *testing.T) {
+	// hcl2shim.UnknownVariableValue, how the SDK marks values that are known after apply.
+	const unknown = "74D93920-ED26-11E3-AC10-0800200C9A66"
+
+	resource := resourceOctalCertManager()
+	config := terraform.NewResourceConfigRaw(map[string]interface{}{
+		"namespace":  "cert-manager",
+		"controller": []interface{}{map[string]interface{}{}},
+		"cainjector": []interface{}{map[string]interface{}{}},
+		"webhook":    []interface{}{map[string]interface{}{}},
+		"cluster":    []interface{}{map[string]interface{}{"host": unknown}},
+	})
+
+	// The provider's client would answer; the plan mustn't ask it about another cluster.
+	diff, err := resource.Diff(context.Background(), nil, config, newTestApiClient())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if attribute, ok := diff.Attributes["rendered_hash"]; !ok || !attribute.NewComputed {
+		t.Errorf("expected rendered_hash to be known after apply, got %#v", diff.Attributes["rendered_hash"])
+	}
+}
@@ -0,0 +1,50 @@
+package octal
+
+import (
+	"context"
+	"sync"
+)
+
+// runPool calls work for every index below count on up to parallelism workers at once, and
+// returns the error of each call at its index. All workers share the one client, so its rate
+// limits hold however many run. Once the context is done the remaining indexes aren't worked
+// on; they get the context's error instead.
+func runPool(ctx context.Context, parallelism int, count int, work func(ctx context.Context, index int) error) []error {
+	if parallelism < 1 {
+		parallelism = 1
+	}
+	if parallelism > count {
+		parallelism = count
+	}
+
+	errs := make([]error, count)
+	indexes := make(chan int)
+
+	var wg sync.WaitGroup
+	for worker := 0; worker < parallelism; worker++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for index := range indexes {
+				if err := ctx.Err(); err != nil {
+					errs[index] = err
+					continue
+				}
+				errs[index] = work(ctx, index)
+			}
+		}()
+	}
+
+	for index := 0; index < count; index++ {
+		indexes <- index
+	}
+	close(indexes)
+	wg.Wait()
+
+	return errs
+}
+
+// parallelism returns how many objects of a phase the client works on at once.
+func parallelism(meta interface{}) int {
+	return meta.(*apiClient).parallelism
+}
@@ -0,0 +1,60 @@
+package octal
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRunPool(t *testing.T) {
+	var lock sync.Mutex
+	running, busiest := 0, 0
+	failing := errors.New("failed")
+
+	errs := runPool(context.Background(), 3, 10, func(ctx context.Context, index int) error {
+		lock.Lock()
+		running++
+		if running > busiest {
+			busiest = running
+		}
+		lock.Unlock()
+
+		time.Sleep(5 * time.Millisecond)
+
+		lock.Lock()
+		running--
+		lock.Unlock()
+
+		if index%4 == 0 {
+			return failing
+		}
+		return nil
+	})
+
+	if busiest > 3 {
+		t.Errorf("expected at most 3 workers at once, got %d", busiest)
+	}
+	for index, err := range errs {
+		if (index%4 == 0) != (err == failing) {
+			t.Errorf("unexpected error for index %d: %v", index, err)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	called := false
+	errs = runPool(ctx, 3, 5, func(ctx context.Context, index int) error {
+		called = true
+		return nil
+	})
+	if called {
+		t.Error("expected no work once the context is cancelled")
+	}
+	for index, err := range errs {
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("expected index %d to be cancelled, got %v", index, err)
+		}
+	}
+}
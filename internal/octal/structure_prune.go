@@ -0,0 +1,72 @@
+package octal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+// inventoryDifference returns the items of previous that current no longer contains.
+func inventoryDifference(previous []inventoryItem, current []inventoryItem) []inventoryItem {
+	keep := map[string]bool{}
+	for _, item := range current {
+		keep[item.key()] = true
+	}
+
+	var difference []inventoryItem
+	for _, item := range previous {
+		if !keep[item.key()] {
+			difference = append(difference, item)
+		}
+	}
+	return difference
+}
+
+// describeInventory names every item the way diagnostics and the plan show objects.
+func describeInventory(inventory []inventoryItem) []string {
+	descriptions := make([]string, len(inventory))
+	for index, item := range inventory {
+		descriptions[index] = fmt.Sprintf("%s %s", item.Kind, objectName(item.object()))
+	}
+	return descriptions
+}
+
+// customizeDiffPrune lists, at plan time, the objects an update is going to prune. Terraform
+// shows them as the new value of `pruned_objects`, so they can be reviewed before applying.
+func customizeDiffPrune(bundle *bundleDefinition) schema.CustomizeDiffFunc {
+	return func(ctx context.Context, d *schema.ResourceDiff, meta interface{}) error {
+		if d.Id() == "" || !d.Get("prune").(bool) {
+			return nil
+		}
+		if !planKnown(bundle, d) {
+			return nil
+		}
+
+		client, err := resourceClient(d, meta)
+		if err != nil {
+			return err
+		}
+
+		objects, err := renderBundle(ctx, bundle, d, client)
+		if errors.Is(err, errConfigUnknown) {
+			// The cluster can't be reached yet; the objects will be pruned all the same.
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		var rendered []inventoryItem
+		for _, object := range objects {
+			rendered = append(rendered, newInventoryItem(object.object))
+		}
+
+		pending := inventoryDifference(expandInventory(d.Get("inventory").([]interface{})), rendered)
+		if len(pending) == 0 {
+			return nil
+		}
+
+		return d.SetNew("pruned_objects", describeInventory(pending))
+	}
+}
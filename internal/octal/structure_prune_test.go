@@ -0,0 +1,52 @@
+package octal
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
+)
+
+func TestInventoryDifference(t *testing.T) {
+	previous := []inventoryItem{
+		{Version: "v1", Kind: "ServiceAccount", Namespace: "cert-manager", Name: "cert-manager", UID: "1"},
+		{Group: "rbac.authorization.k8s.io", Version: "v1", Kind: "ClusterRole", Name: "cert-manager-controller-issuers", UID: "2"},
+		{Group: "admissionregistration.k8s.io", Version: "v1beta1", Kind: "ValidatingWebhookConfiguration", Name: "cert-manager-webhook", UID: "3"},
+	}
+	current := []inventoryItem{
+		{Version: "v1", Kind: "ServiceAccount", Namespace: "cert-manager", Name: "cert-manager", UID: "1"},
+		// A new API version of the same object isn't a leftover.
+		{Group: "admissionregistration.k8s.io", Version: "v1", Kind: "ValidatingWebhookConfiguration", Name: "cert-manager-webhook", UID: "3"},
+	}
+
+	leftovers := inventoryDifference(previous, current)
+	if expected := []string{"ClusterRole cert-manager-controller-issuers"}; !reflect.DeepEqual(describeInventory(leftovers), expected) {
+		t.Fatalf("expected %v, got %v", expected, describeInventory(leftovers))
+	}
+}
+
+func TestCustomizeDiffPrune(t *testing.T) {
+	bundle := testBundle()
+	resource := resourceBundle(bundle)
+	resource.CustomizeDiff = customizeDiffPrune(bundle)
+
+	config := map[string]interface{}{"namespace": "octal"}
+	state := schema.TestResourceDataRaw(t, resource.Schema, config)
+	state.SetId("instance")
+	state.Set("inventory", flattenInventory([]inventoryItem{
+		{Group: "apps", Version: "v1", Kind: "Deployment", Namespace: "octal", Name: "operator", UID: "1"},
+		{Version: "v1", Kind: "ServiceAccount", Namespace: "octal", Name: "operator", UID: "2"},
+		{Group: "rbac.authorization.k8s.io", Version: "v1", Kind: "ClusterRole", Name: "operator-leases", UID: "3"},
+	}))
+
+	// The plan is the only place the objects to prune are shown before they're deleted.
+	diff, err := resource.Diff(context.Background(), state.State(), terraform.NewResourceConfigRaw(config), newTestApiClient())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if attribute := diff.Attributes["pruned_objects.0"]; attribute == nil || attribute.New != "ClusterRole operator-leases" {
+		t.Errorf("expected the ClusterRole to be planned for pruning, got %#v", diff)
+	}
+}
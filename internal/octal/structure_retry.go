@@ -0,0 +1,108 @@
+package octal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+var (
+	// retryInitialDelay is the wait before the first retry. It doubles with every attempt,
+	// up to retryMaxDelay.
+	retryInitialDelay = 500 * time.Millisecond
+	retryMaxDelay     = 30 * time.Second
+	// retryAttempts bounds the retries of calls whose context has no deadline.
+	retryAttempts = 10
+	// retryJitter spreads out the retries of calls that failed together.
+	retryJitter = 0.5
+)
+
+// retryTransient runs an API call, and runs it again with an exponential backoff for as
+// long as it fails in a way that's expected to pass: conflicting writes, throttling, errors
+// of the API server, and admission webhooks that can't be reached yet, like cert-manager's
+// own while it starts. Retries stop at the context's deadline, or after retryAttempts when
+// it has none; the last error is returned.
+func retryTransient(ctx context.Context, description string, call func() error) error {
+	delay := retryInitialDelay
+	_, bounded := ctx.Deadline()
+	for attempt := 1; ; attempt++ {
+		err := call()
+		if err == nil || !isTransient(err) || (!bounded && attempt >= retryAttempts) {
+			return err
+		}
+
+		pause := wait.Jitter(delay, retryJitter)
+		if seconds, ok := apierrors.SuggestsClientDelay(err); ok && time.Duration(seconds)*time.Second > pause {
+			pause = time.Duration(seconds) * time.Second
+		}
+		if deadline, ok := ctx.Deadline(); ok && time.Now().Add(pause).After(deadline) {
+			return err
+		}
+		tflog.Debug(ctx, fmt.Sprintf("Retrying %s in %s: %s", description, pause, err))
+
+		timer := time.NewTimer(pause)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+
+		delay *= 2
+		if delay > retryMaxDelay {
+			delay = retryMaxDelay
+		}
+	}
+}
+
+// isTransient reports whether an API error is worth retrying.
+func isTransient(err error) bool {
+	switch {
+	case isWebhookUnavailable(err):
+		return true
+	case apierrors.IsConflict(err):
+		// Field ownership conflicts come back the same until someone resolves them.
+		return !isFieldManagerConflict(err)
+	case apierrors.IsTooManyRequests(err), apierrors.IsServerTimeout(err), apierrors.IsTimeout(err),
+		apierrors.IsServiceUnavailable(err), apierrors.IsInternalError(err), apierrors.IsUnexpectedServerError(err):
+		return true
+	}
+
+	var status apierrors.APIStatus
+	return errors.As(err, &status) && status.Status().Code >= 500
+}
+
+// isWebhookUnavailable reports whether the API server failed to call an admission webhook,
+// as it does while the webhook's pods aren't serving yet.
+func isWebhookUnavailable(err error) bool {
+	message := err.Error()
+	if !strings.Contains(message, "failed calling webhook") {
+		return false
+	}
+	for _, reason := range []string{"connection refused", "no endpoints available", "i/o timeout", "context deadline exceeded", "EOF"} {
+		if strings.Contains(message, reason) {
+			return true
+		}
+	}
+	return false
+}
+
+func isFieldManagerConflict(err error) bool {
+	var status apierrors.APIStatus
+	if !errors.As(err, &status) || status.Status().Details == nil {
+		return false
+	}
+	for _, cause := range status.Status().Details.Causes {
+		if cause.Type == metav1.CauseTypeFieldManagerConflict {
+			return true
+		}
+	}
+	return false
+}
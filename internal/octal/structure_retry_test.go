@@ -0,0 +1,86 @@
+package octal
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestRetryTransient(t *testing.T) {
+	defer func(delay time.Duration, attempts int) {
+		retryInitialDelay, retryAttempts = delay, attempts
+	}(retryInitialDelay, retryAttempts)
+	retryInitialDelay = time.Millisecond
+
+	deployments := schema.GroupResource{Group: "apps", Resource: "deployments"}
+	webhook := apierrors.NewInternalError(errors.New(`failed calling webhook "webhook.cert-manager.io": ` +
+		`Post "https://cert-manager-webhook.cert-manager.svc:443/mutate": dial tcp 10.96.0.1:443: connect: connection refused`))
+	conflict := apierrors.NewApplyConflict([]metav1.StatusCause{
+		{Type: metav1.CauseTypeFieldManagerConflict, Field: ".spec.replicas"},
+	}, "Apply failed with 1 conflict")
+
+	cases := []struct {
+		name     string
+		errors   []error
+		expected int
+	}{
+		{"throttled", []error{apierrors.NewTooManyRequests("slow down", 0), apierrors.NewTooManyRequests("slow down", 0)}, 3},
+		{"write conflict", []error{apierrors.NewConflict(deployments, "cert-manager", errors.New("the object has been modified"))}, 2},
+		{"server error", []error{apierrors.NewServiceUnavailable("etcd is down")}, 2},
+		{"webhook starting", []error{webhook}, 2},
+		{"not found", []error{apierrors.NewNotFound(deployments, "cert-manager")}, 1},
+		{"field ownership conflict", []error{conflict}, 1},
+	}
+	for _, c := range cases {
+		calls := 0
+		err := retryTransient(context.Background(), c.name, func() error {
+			calls++
+			if calls <= len(c.errors) {
+				return c.errors[calls-1]
+			}
+			return nil
+		})
+		if calls != c.expected {
+			t.Errorf("%s: expected %d calls, got %d (%v)", c.name, c.expected, calls, err)
+		}
+	}
+
+	// The attempts are only bounded without a deadline.
+	retryAttempts = 2
+	throttled := func(calls *int) func() error {
+		return func() error {
+			*calls++
+			if *calls <= 3 {
+				return apierrors.NewTooManyRequests("slow down", 0)
+			}
+			return nil
+		}
+	}
+	unbounded := 0
+	if err := retryTransient(context.Background(), "unbounded", throttled(&unbounded)); unbounded != 2 || err == nil {
+		t.Errorf("expected the retries to stop after 2 calls without a deadline, got %d calls and %v", unbounded, err)
+	}
+	bounded := 0
+	boundedCtx, boundedCancel := context.WithTimeout(context.Background(), time.Minute)
+	defer boundedCancel()
+	if err := retryTransient(boundedCtx, "bounded", throttled(&bounded)); bounded != 4 || err != nil {
+		t.Errorf("expected the retries to go on until the deadline, got %d calls and %v", bounded, err)
+	}
+
+	retryInitialDelay = time.Second
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	calls := 0
+	err := retryTransient(ctx, "deadline", func() error {
+		calls++
+		return apierrors.NewTooManyRequests("slow down", 0)
+	})
+	if calls != 1 || !apierrors.IsTooManyRequests(err) {
+		t.Errorf("expected a single call within the deadline, got %d calls and %v", calls, err)
+	}
+}
@@ -0,0 +1,70 @@
+package octal
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+)
+
+const (
+	// onFailureRollback deletes what a failed create applied, leaving nothing in state.
+	onFailureRollback = "rollback"
+	// onFailureKeep keeps what a failed create applied; Terraform taints the resource.
+	onFailureKeep = "keep"
+)
+
+// detachedContext keeps the values of a context, which carry its logger, but neither its
+// deadline nor its cancellation.
+type detachedContext struct {
+	parent context.Context
+}
+
+func (detachedContext) Deadline() (time.Time, bool) { return time.Time{}, false }
+func (detachedContext) Done() <-chan struct{}       { return nil }
+func (detachedContext) Err() error                  { return nil }
+
+func (c detachedContext) Value(key interface{}) interface{} {
+	return c.parent.Value(key)
+}
+
+// rollbackContext gives the rollback of a failed create a context of its own, bounded by
+// timeout. The create's own context may be what ran out, and deleting with it would fail
+// every call, leaving the half-installed bundle behind.
+func rollbackContext(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(detachedContext{ctx}, timeout)
+}
+
+// rollbackObjects deletes the objects a failed create applied. Adopted objects existed
+// before the create, so they're left in place. When deleting fails, it returns the whole
+// inventory, to be kept in state for the tainted resource.
+func rollbackObjects(ctx context.Context, meta interface{}, inventory []inventoryItem, objects []bundleObject) ([]inventoryItem, diag.Diagnostics) {
+	adopted := map[string]bool{}
+	for _, object := range objects {
+		if object.adopted {
+			adopted[newInventoryItem(object.object).key()] = true
+		}
+	}
+
+	var created, kept []inventoryItem
+	for _, item := range inventory {
+		if adopted[item.key()] {
+			kept = append(kept, item)
+			continue
+		}
+		created = append(created, item)
+	}
+	if len(kept) > 0 {
+		tflog.Warn(ctx, fmt.Sprintf("Leaving the adopted objects in place: %s", strings.Join(describeInventory(kept), ", ")))
+	}
+
+	tflog.Info(ctx, fmt.Sprintf("Rolling back %d objects", len(created)))
+	diags := deleteObjects(ctx, meta, inventoryObjects(created))
+	if diags.HasError() {
+		return inventory, diags
+	}
+	return nil, diags
+}
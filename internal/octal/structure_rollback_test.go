@@ -0,0 +1,75 @@
+package octal
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+func TestRollbackObjects(t *testing.T) {
+	client := newTestApiClient(
+		testObject(testServiceAccountKind, "cert-manager", "cert-manager", nil),
+		testObject(testClusterRoleKind, "", "cert-manager-controller-issuers", nil),
+	)
+
+	serviceAccount := testObject(testServiceAccountKind, "cert-manager", "cert-manager", nil)
+	clusterRole := testObject(testClusterRoleKind, "", "cert-manager-controller-issuers", nil)
+	objects := []bundleObject{
+		{component: "controller", object: serviceAccount, adopted: true},
+		{component: "controller", object: clusterRole},
+	}
+
+	remaining, diags := rollbackObjects(context.Background(), client, []inventoryItem{
+		newInventoryItem(serviceAccount),
+		newInventoryItem(clusterRole),
+	}, objects)
+	if diags.HasError() {
+		t.Fatalf("unexpected diagnostics: %#v", diags)
+	}
+	if len(remaining) != 0 {
+		t.Errorf("expected nothing to remain, got %#v", remaining)
+	}
+
+	if _, err := getObject(context.Background(), client, clusterRole); !apierrors.IsNotFound(err) {
+		t.Errorf("expected the created ClusterRole to be deleted, got %v", err)
+	}
+	if _, err := getObject(context.Background(), client, serviceAccount); err != nil {
+		t.Errorf("expected the adopted ServiceAccount to be left in place, got %v", err)
+	}
+}
+
+func TestRollbackAfterReadinessTimeout(t *testing.T) {
+	defer func(interval, reserve time.Duration) {
+		waitPollInterval, waitReserve = interval, reserve
+	}(waitPollInterval, waitReserve)
+	waitPollInterval, waitReserve = 10*time.Millisecond, 100*time.Millisecond
+
+	deployment := testDeployment(1, 1, 1, 0, 0, 0)
+	client := newTestApiClient(deployment)
+	objects := []bundleObject{{component: "controller", object: testObject(testDeploymentKind, "cert-manager", "cert-manager", nil)}}
+
+	// The create's timeout, as the SDK sets it on the context.
+	ctx, cancel := context.WithTimeout(context.Background(), 400*time.Millisecond)
+	defer cancel()
+
+	if diags := waitForReady(ctx, client, objects, time.Hour); !diags.HasError() {
+		t.Fatal("expected the wait to time out")
+	}
+	if err := ctx.Err(); err != nil {
+		t.Fatalf("expected the wait to leave time for what follows it, got %v", err)
+	}
+
+	// Even once the create's context is done, the rollback gets to delete.
+	cancel()
+	rollbackCtx, cancelRollback := rollbackContext(ctx, time.Minute)
+	defer cancelRollback()
+	remaining, diags := rollbackObjects(rollbackCtx, client, []inventoryItem{newInventoryItem(deployment)}, objects)
+	if diags.HasError() || len(remaining) != 0 {
+		t.Fatalf("expected the rollback to delete everything, got %#v and %#v", remaining, diags)
+	}
+	if _, err := getObject(context.Background(), client, deployment); !apierrors.IsNotFound(err) {
+		t.Errorf("expected the Deployment to be deleted, got %v", err)
+	}
+}
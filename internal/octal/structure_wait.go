@@ -0,0 +1,277 @@
+package octal
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/go-cty/cty"
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	k8sschema "k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+// waitPollInterval is how often the provider checks on objects it's waiting for.
+var waitPollInterval = 2 * time.Second
+
+// waitReserve is the time a readiness wait leaves of its operation's timeout, so whatever
+// follows a wait that times out, like a rollback or the final read, still gets to run.
+var waitReserve = time.Minute
+
+// readinessCheck is something Create and Update wait for before they return.
+type readinessCheck struct {
+	// description names what's being waited for, e.g. `Deployment cert-manager/cert-manager`.
+	description string
+	// path points at the configuration of the component the check belongs to.
+	path cty.Path
+	// ready reports whether the check passed, and if not, why.
+	ready func(ctx context.Context, meta interface{}) (bool, string, error)
+}
+
+// readinessChecks returns what needs to be ready before the bundle can be used: rolled out
+// Deployments, established CRDs, webhook Services with endpoints to send requests to, and
+// custom resources that report a `Ready` condition.
+func readinessChecks(objects []bundleObject) []readinessCheck {
+	var checks []readinessCheck
+	// Several webhooks are usually served by the same Service.
+	services := map[string]bool{}
+
+	for _, bundleObject := range objects {
+		object := bundleObject.object
+		description := fmt.Sprintf("%s %s", object.GetKind(), objectName(object))
+		path := componentPath(bundleObject.component)
+
+		switch {
+		case object.GroupVersionKind().GroupKind() == (k8sschema.GroupKind{Group: "apps", Kind: "Deployment"}):
+			checks = append(checks, readinessCheck{description, path, func(ctx context.Context, meta interface{}) (bool, string, error) {
+				live, err := getObject(ctx, meta, object)
+				if err != nil {
+					return false, "", err
+				}
+				ready, reason := deploymentReady(live)
+				return ready, reason, nil
+			}})
+
+		case objectPhase(object) == phaseCustomResourceDefinitions:
+			checks = append(checks, readinessCheck{description, path, func(ctx context.Context, meta interface{}) (bool, string, error) {
+				live, err := getObject(ctx, meta, object)
+				if err != nil {
+					return false, "", err
+				}
+				return hasCondition(live, "Established", "True"), "the API server doesn't serve its kind yet", nil
+			}})
+
+		case objectPhase(object) == phaseWebhooks:
+			for _, service := range webhookServices(object) {
+				service := service
+				if services[objectName(service)] {
+					continue
+				}
+				services[objectName(service)] = true
+				checks = append(checks, readinessCheck{fmt.Sprintf("Service %s", objectName(service)), path, func(ctx context.Context, meta interface{}) (bool, string, error) {
+					endpoints := &unstructured.Unstructured{}
+					endpoints.SetAPIVersion("v1")
+					endpoints.SetKind("Endpoints")
+					endpoints.SetNamespace(service.GetNamespace())
+					endpoints.SetName(service.GetName())
+
+					live, err := getObject(ctx, meta, endpoints)
+					if apierrors.IsNotFound(err) {
+						return false, "it has no endpoints yet", nil
+					}
+					if err != nil {
+						return false, "", err
+					}
+					return hasReadyEndpoints(live), fmt.Sprintf("no ready pods back the %s webhook yet", object.GetName()), nil
+				}})
+			}
+
+		case isCustomResource(object):
+			checks = append(checks, readinessCheck{description, path, func(ctx context.Context, meta interface{}) (bool, string, error) {
+				live, err := getObject(ctx, meta, object)
+				if err != nil {
+					return false, "", err
+				}
+				return hasCondition(live, "Ready", "True"), "its `Ready` condition isn't `True` yet", nil
+			}})
+		}
+	}
+
+	return checks
+}
+
+// waitForReady waits until every readiness check passes or the timeout runs out. Checks
+// still failing at that point are reported one diagnostic each.
+func waitForReady(ctx context.Context, meta interface{}, objects []bundleObject, timeout time.Duration) diag.Diagnostics {
+	var diags diag.Diagnostics
+
+	timeout = waitBudget(ctx, timeout)
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	pending := readinessChecks(objects)
+	reasons := map[string]string{}
+
+	err := wait.PollImmediateUntilWithContext(ctx, waitPollInterval, func(ctx context.Context) (bool, error) {
+		var notReady []readinessCheck
+		for _, check := range pending {
+			ready, reason, err := check.ready(ctx, meta)
+			if err != nil {
+				// Errors reading the object are retried; it may just not have been created
+				// by its controller yet.
+				reason = errorDetail(err)
+			}
+			if !ready {
+				notReady = append(notReady, check)
+				reasons[check.description] = reason
+				continue
+			}
+			tflog.Info(ctx, fmt.Sprintf("%s is ready", check.description))
+		}
+		pending = notReady
+		return len(pending) == 0, nil
+	})
+	if err == nil {
+		return diags
+	}
+
+	for _, check := range pending {
+		diags = append(diags, diag.Diagnostic{
+			Severity: diag.Error,
+			Summary:  fmt.Sprintf("Timed out waiting for %s to become ready", check.description),
+			Detail: fmt.Sprintf("%s was still not ready after %s: %s\n\n"+
+				"Check its events on the cluster, or raise the `timeouts` of this resource if it's just slow.",
+				check.description, timeout, reasons[check.description]),
+			AttributePath: check.path,
+		})
+	}
+	return diags
+}
+
+// waitBudget caps a wait at the time left on the context, less waitReserve, or less half of
+// it when that's shorter.
+func waitBudget(ctx context.Context, timeout time.Duration) time.Duration {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return timeout
+	}
+
+	left := time.Until(deadline)
+	reserve := waitReserve
+	if reserve > left/2 {
+		reserve = left / 2
+	}
+	if budget := left - reserve; budget < timeout {
+		return budget
+	}
+	return timeout
+}
+
+// waitForEstablished waits until the API server serves the kind a CustomResourceDefinition
+// defines.
+func waitForEstablished(ctx context.Context, meta interface{}, crd *unstructured.Unstructured) error {
+	return wait.PollImmediateUntilWithContext(ctx, waitPollInterval, func(ctx context.Context) (bool, error) {
+		live, err := getObject(ctx, meta, crd)
+		if err != nil {
+			return false, err
+		}
+		return hasCondition(live, "Established", "True"), nil
+	})
+}
+
+// deploymentReady reports whether a Deployment finished rolling out, the same way
+// `kubectl rollout status` does.
+func deploymentReady(deployment *unstructured.Unstructured) (bool, string) {
+	generation := deployment.GetGeneration()
+	observedGeneration, _, _ := unstructured.NestedInt64(deployment.Object, "status", "observedGeneration")
+	if observedGeneration < generation {
+		return false, "its controller hasn't seen the latest spec yet"
+	}
+
+	replicas, found, _ := unstructured.NestedInt64(deployment.Object, "spec", "replicas")
+	if !found {
+		replicas = 1
+	}
+	updated, _, _ := unstructured.NestedInt64(deployment.Object, "status", "updatedReplicas")
+	available, _, _ := unstructured.NestedInt64(deployment.Object, "status", "availableReplicas")
+	total, _, _ := unstructured.NestedInt64(deployment.Object, "status", "replicas")
+
+	switch {
+	case updated < replicas:
+		return false, fmt.Sprintf("%d of %d replicas have been updated", updated, replicas)
+	case total > updated:
+		return false, fmt.Sprintf("%d old replicas are pending termination", total-updated)
+	case available < updated:
+		return false, fmt.Sprintf("%d of %d updated replicas are available", available, updated)
+	}
+	return true, ""
+}
+
+// webhookServices returns the Services a webhook configuration sends its requests to.
+func webhookServices(configuration *unstructured.Unstructured) []*unstructured.Unstructured {
+	var services []*unstructured.Unstructured
+
+	webhooks, _, _ := unstructured.NestedSlice(configuration.Object, "webhooks")
+	for _, webhook := range webhooks {
+		w, ok := webhook.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		name, _, _ := unstructured.NestedString(w, "clientConfig", "service", "name")
+		namespace, _, _ := unstructured.NestedString(w, "clientConfig", "service", "namespace")
+		if name == "" {
+			continue
+		}
+
+		service := &unstructured.Unstructured{}
+		service.SetAPIVersion("v1")
+		service.SetKind("Service")
+		service.SetNamespace(namespace)
+		service.SetName(name)
+		services = append(services, service)
+	}
+
+	return services
+}
+
+// hasReadyEndpoints reports whether at least one ready address backs the Endpoints.
+func hasReadyEndpoints(endpoints *unstructured.Unstructured) bool {
+	subsets, _, _ := unstructured.NestedSlice(endpoints.Object, "subsets")
+	for _, subset := range subsets {
+		s, ok := subset.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		addresses, _, _ := unstructured.NestedSlice(s, "addresses")
+		if len(addresses) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// isCustomResource reports whether the object's kind comes from a CRD rather than
+// Kubernetes itself. Built-in groups are either unqualified or end in `.k8s.io`.
+func isCustomResource(object *unstructured.Unstructured) bool {
+	group := object.GroupVersionKind().Group
+	return strings.Contains(group, ".") && !strings.HasSuffix(group, ".k8s.io")
+}
+
+// hasCondition reports whether the object's status carries the condition with the given status.
+func hasCondition(object *unstructured.Unstructured, conditionType string, status string) bool {
+	conditions, _, _ := unstructured.NestedSlice(object.Object, "status", "conditions")
+	for _, condition := range conditions {
+		c, ok := condition.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if c["type"] == conditionType && c["status"] == status {
+			return true
+		}
+	}
+	return false
+}
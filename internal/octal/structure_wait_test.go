@@ -0,0 +1,64 @@
+package octal
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/go-cty/cty"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func testDeployment(generation, observedGeneration, replicas, updated, available, total int64) *unstructured.Unstructured {
+	deployment := testObject(testDeploymentKind, "cert-manager", "cert-manager", nil)
+	deployment.SetGeneration(generation)
+	unstructured.SetNestedField(deployment.Object, replicas, "spec", "replicas")
+	unstructured.SetNestedField(deployment.Object, observedGeneration, "status", "observedGeneration")
+	unstructured.SetNestedField(deployment.Object, updated, "status", "updatedReplicas")
+	unstructured.SetNestedField(deployment.Object, available, "status", "availableReplicas")
+	unstructured.SetNestedField(deployment.Object, total, "status", "replicas")
+	return deployment
+}
+
+func TestDeploymentReady(t *testing.T) {
+	cases := map[string]struct {
+		deployment *unstructured.Unstructured
+		ready      bool
+	}{
+		"rolled out":          {testDeployment(2, 2, 2, 2, 2, 2), true},
+		"stale generation":    {testDeployment(3, 2, 2, 2, 2, 2), false},
+		"updating":            {testDeployment(2, 2, 2, 1, 2, 2), false},
+		"old replicas remain": {testDeployment(2, 2, 2, 2, 2, 3), false},
+		"unavailable":         {testDeployment(2, 2, 2, 2, 1, 2), false},
+	}
+
+	for name, c := range cases {
+		if ready, reason := deploymentReady(c.deployment); ready != c.ready {
+			t.Errorf("%s: expected ready to be %t, got %t (%s)", name, c.ready, ready, reason)
+		}
+	}
+}
+
+func TestWaitForReadyTimeout(t *testing.T) {
+	defer func(interval time.Duration) { waitPollInterval = interval }(waitPollInterval)
+	waitPollInterval = 10 * time.Millisecond
+
+	client := newTestApiClient(testDeployment(1, 1, 1, 0, 0, 0))
+
+	diags := waitForReady(context.Background(), client, []bundleObject{
+		{component: "controller", object: testObject(testDeploymentKind, "cert-manager", "cert-manager", nil)},
+	}, 50*time.Millisecond)
+	if len(diags) != 1 {
+		t.Fatalf("expected a single diagnostic, got %#v", diags)
+	}
+	if !strings.Contains(diags[0].Summary, "Deployment cert-manager/cert-manager") {
+		t.Errorf("expected the diagnostic to name the Deployment, got %q", diags[0].Summary)
+	}
+	if !strings.Contains(diags[0].Detail, "0 of 1 replicas have been updated") {
+		t.Errorf("expected the diagnostic to explain what's missing, got %q", diags[0].Detail)
+	}
+	if !diags[0].AttributePath.Equals(cty.GetAttrPath("controller").IndexInt(0)) {
+		t.Errorf("expected the diagnostic to point at the controller block, got %#v", diags[0].AttributePath)
+	}
+}
@@ -3,8 +3,10 @@ package octal
 import (
 	"encoding/base64"
 	"fmt"
+	"net/url"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
 	"k8s.io/apimachinery/pkg/api/resource"
@@ -189,6 +191,16 @@ func validateTerminationGracePeriodSeconds(value interface{}, key string) (ws []
 	return
 }
 
+func validateFloatGreaterThan(minValue float64) func(value interface{}, key string) (ws []string, es []error) {
+	return func(value interface{}, key string) (ws []string, es []error) {
+		v := value.(float64)
+		if v <= minValue {
+			es = append(es, fmt.Errorf("%s must be greater than %v", key, minValue))
+		}
+		return
+	}
+}
+
 func validateIntGreaterThan(minValue int) func(value interface{}, key string) (ws []string, es []error) {
 	return func(value interface{}, key string) (ws []string, es []error) {
 		v := value.(int)
@@ -290,3 +302,43 @@ func validateTypeStringNullableIntOrPercent(v interface{}, key string) (ws []str
 
 	return
 }
+
+func validateDuration(value interface{}, key string) (ws []string, es []error) {
+	v := value.(string)
+	if v == "" {
+		return
+	}
+	if _, err := time.ParseDuration(v); err != nil {
+		es = append(es, fmt.Errorf("%s: cannot parse '%s' as a duration: %s", key, v, err))
+	}
+	return
+}
+
+func validateProxyURL(value interface{}, key string) (ws []string, es []error) {
+	v := value.(string)
+	if v == "" {
+		return
+	}
+	u, err := url.Parse(v)
+	if err != nil {
+		es = append(es, fmt.Errorf("%s: cannot parse '%s' as a URL: %s", key, v, err))
+		return
+	}
+	switch u.Scheme {
+	case "http", "https", "socks5":
+	default:
+		es = append(es, fmt.Errorf("%s: unsupported proxy scheme %q, expected one of http, https or socks5", key, u.Scheme))
+	}
+	return
+}
+
+// validateBundleVersion accepts the versions of the bundle and constraints that select one
+// of them.
+func validateBundleVersion(bundle *bundleDefinition) schema.SchemaValidateFunc {
+	return func(value interface{}, key string) (ws []string, es []error) {
+		if _, err := bundle.resolveVersion(value.(string)); err != nil {
+			es = append(es, fmt.Errorf("%s: %s", key, err))
+		}
+		return
+	}
+}
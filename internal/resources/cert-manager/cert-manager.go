@@ -20,6 +20,6 @@ func (cmm CertManagerManifests) CertManagerManifests() {
 
 }
 
-func (cmm CertManagerManifests) GetDefaultNamespace(ctx context.Context) *Corev1.Namespace {
+func (cmm CertManagerManifests) GetDefaultNamespace(ctx context.Context) (*Corev1.Namespace, error) {
 	return namespace.GetDefaultNamespace(ctx)
 }
@@ -3,19 +3,20 @@ package namespace
 import (
 	"context"
 	_ "embed"
+	"fmt"
+
 	"github.com/dylanturn/terraform-provider-octal/internal/util"
-	"github.com/hashicorp/terraform-plugin-log/tflog"
 	Corev1 "k8s.io/api/core/v1"
 )
 
 //go:embed namespace.yml
 var namespace []byte
 
-func GetDefaultNamespace(ctx context.Context) *Corev1.Namespace {
+func GetDefaultNamespace(ctx context.Context) (*Corev1.Namespace, error) {
 	namespaceObject := &Corev1.Namespace{}
 	err := util.DecodeManifest(namespace).Decode(&namespaceObject)
 	if err != nil {
-		tflog.Error(ctx, err.Error())
+		return nil, fmt.Errorf("failed to decode the namespace manifest: %s", err)
 	}
-	return namespaceObject
+	return namespaceObject, nil
 }
@@ -2,54 +2,174 @@ package util
 
 import (
 	"bytes"
-	"context"
-	"embed"
+	"encoding/json"
 	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"strings"
+	"text/template"
 
-	Appsv1 "k8s.io/api/apps/v1"
+	"github.com/Masterminds/sprig/v3"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	utiljson "k8s.io/apimachinery/pkg/util/json"
 	k8Yaml "k8s.io/apimachinery/pkg/util/yaml"
 )
 
-func LoadDeploymentObj(ctx context.Context, objManifest string) *Appsv1.Deployment {
-	deploymentObject := &Appsv1.Deployment{}
-	LoadManifest(objManifest).Decode(&deploymentObject)
-	return deploymentObject
+// manifestExtensions are the files the loader reads; anything else in a bundle directory,
+// such as a README, is skipped.
+var manifestExtensions = map[string]bool{".yml": true, ".yaml": true, ".json": true}
+
+// Manifest is a file of one or more Kubernetes objects, in YAML or JSON.
+type Manifest struct {
+	// Path is where the file is in the filesystem it was read from. Errors point at it.
+	Path    string
+	Content []byte
 }
 
 func DecodeManifest(manifest []byte) *k8Yaml.YAMLOrJSONDecoder {
 	return k8Yaml.NewYAMLOrJSONDecoder(bytes.NewReader(manifest), 1000)
 }
 
-func LoadManifest(manifestPath string) *k8Yaml.YAMLOrJSONDecoder {
-	return k8Yaml.NewYAMLOrJSONDecoder(bytes.NewReader(readFile(manifestPath)), 1000)
+// ReadEmbeddedVersions reads manifests embedded per upstream release, one directory per
+// version such as `v1.8.2`, and returns the manifests of each keyed by the version
+// without its leading "v". Embedding no release at all is an error.
+func ReadEmbeddedVersions(embeddedFs fs.FS) (map[string][]Manifest, error) {
+	directories, err := fs.ReadDir(embeddedFs, ".")
+	if err != nil {
+		return nil, err
+	}
+
+	versions := map[string][]Manifest{}
+	for _, directory := range directories {
+		if !directory.IsDir() || !strings.HasPrefix(directory.Name(), "v") {
+			continue
+		}
+		manifests, err := ReadEmbeddedFiles(embeddedFs, directory.Name())
+		if err != nil {
+			return nil, err
+		}
+		versions[strings.TrimPrefix(directory.Name(), "v")] = manifests
+	}
+	if len(versions) == 0 {
+		return nil, fmt.Errorf("no release directories, such as v1.0.0, are embedded")
+	}
+	return versions, nil
 }
 
-func ReadEmbeddedFiles(embeddedFs embed.FS) []string {
-	embeddedDirectories, err := embeddedFs.ReadDir(".")
+// ReadComponentManifests reads the releases a component embeds, as ReadEmbeddedVersions does.
+// The manifests are embedded when the provider is built, so an error can only come from a
+// broken release directory, which any test loading the component catches.
+func ReadComponentManifests(component string, embeddedFs fs.FS) (map[string][]Manifest, error) {
+	versions, err := ReadEmbeddedVersions(embeddedFs)
 	if err != nil {
-		fmt.Println("Failed to read embedded directory", err.Error())
+		return nil, fmt.Errorf("failed to load the %s manifests: %s", component, err)
 	}
+	return versions, nil
+}
 
-	for _, directory := range embeddedDirectories {
+// ReadEmbeddedFiles reads every manifest below root, descending into every directory.
+// Files are returned in lexical order of their path, so a bundle renders the same way on
+// every run. A root that's missing or holds no manifests is an error.
+func ReadEmbeddedFiles(embeddedFs fs.FS, root string) ([]Manifest, error) {
+	var manifests []Manifest
+	err := fs.WalkDir(embeddedFs, root, func(filePath string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() || !manifestExtensions[path.Ext(filePath)] {
+			return nil
+		}
 
-		fmt.Println(directory.Name())
+		content, err := fs.ReadFile(embeddedFs, filePath)
+		if err != nil {
+			return err
+		}
+		manifests = append(manifests, Manifest{Path: filePath, Content: content})
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the manifests in %s: %s", root, err)
+	}
+	if len(manifests) == 0 {
+		return nil, fmt.Errorf("%s holds no manifests", root)
+	}
+	return manifests, nil
+}
 
-		fileList, err := embed.FS.ReadDir(embeddedFs, directory.Name())
+// Render executes the manifest as a Go template, with the functions of the sprig library,
+// and returns the manifest it renders to. Referring to a value that isn't set is an error,
+// so a typo doesn't render to an empty string.
+func (manifest Manifest) Render(values interface{}) (Manifest, error) {
+	manifestTemplate, err := template.New(manifest.Path).
+		Funcs(sprig.TxtFuncMap()).
+		Option("missingkey=error").
+		Parse(string(manifest.Content))
+	if err != nil {
+		return Manifest{}, err
+	}
+
+	var rendered bytes.Buffer
+	if err := manifestTemplate.Execute(&rendered, values); err != nil {
+		return Manifest{}, err
+	}
+	return Manifest{Path: manifest.Path, Content: rendered.Bytes()}, nil
+}
+
+// DecodeObjects decodes every object in a manifest. Documents are separated by `---`, and
+// may be YAML or JSON. Empty documents are skipped, and a `List` is expanded into its
+// items. Errors name the file and the index of the document, counting from 0.
+func (manifest Manifest) DecodeObjects() ([]unstructured.Unstructured, error) {
+	var objects []unstructured.Unstructured
+
+	decoder := DecodeManifest(manifest.Content)
+	for index := 0; ; index++ {
+		var document json.RawMessage
+		err := decoder.Decode(&document)
+		if err == io.EOF {
+			return objects, nil
+		}
 		if err != nil {
-			fmt.Println("Failed to read embedded directory", err.Error())
+			return nil, fmt.Errorf("%s: document %d: %s", manifest.Path, index, err)
+		}
+		if len(bytes.TrimSpace(document)) == 0 {
+			continue
+		}
+		// Numbers are decoded the way the API machinery does, integers to int64, so they
+		// compare equal to the objects read back from the cluster.
+		content := map[string]interface{}{}
+		if err := utiljson.Unmarshal(document, &content); err != nil {
+			return nil, fmt.Errorf("%s: document %d: %s", manifest.Path, index, err)
+		}
+		if len(content) == 0 {
+			continue
 		}
 
-		fileContentStrings := make([]string, len(fileList))
+		object := unstructured.Unstructured{Object: content}
+		if err := validateObject(object); err != nil {
+			return nil, fmt.Errorf("%s: document %d: %s", manifest.Path, index, err)
+		}
+		if !object.IsList() {
+			objects = append(objects, object)
+			continue
+		}
 
-		for index, file := range fileList {
-			fmt.Println(file.Name())
-			fileContents, err := embeddedFs.ReadFile(fmt.Sprintf("%s/%s", directory.Name(), file.Name()))
-			if err != nil {
-				fmt.Println("Failed to read file", err.Error())
+		list, err := object.ToList()
+		if err != nil {
+			return nil, fmt.Errorf("%s: document %d: %s", manifest.Path, index, err)
+		}
+		for item := range list.Items {
+			if err := validateObject(list.Items[item]); err != nil {
+				return nil, fmt.Errorf("%s: document %d: item %d: %s", manifest.Path, index, item, err)
 			}
-			fileContentStrings[index] = string(fileContents)
+			objects = append(objects, list.Items[item])
 		}
-		return fileContentStrings
 	}
-	return []string{}
+}
+
+func validateObject(object unstructured.Unstructured) error {
+	if object.GetKind() == "" || object.GetAPIVersion() == "" {
+		return fmt.Errorf("the object has no kind or apiVersion")
+	}
+	return nil
 }
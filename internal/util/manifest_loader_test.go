@@ -0,0 +1,109 @@
+package util
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestReadEmbeddedVersions(t *testing.T) {
+	serviceAccount := "apiVersion: v1\nkind: ServiceAccount\nmetadata:\n  name: operator\n"
+	embedded := fstest.MapFS{
+		"notes.txt":                             {Data: []byte("not a release")},
+		"v1.8.2/roles/b.yml":                    {Data: []byte(serviceAccount)},
+		"v1.8.2/roles/a.yml":                    {Data: []byte(serviceAccount)},
+		"v1.8.2/crds/nested/list.yaml":          {Data: []byte(serviceAccount)},
+		"v1.8.2/service.json":                   {Data: []byte(`{"apiVersion": "v1", "kind": "Service", "metadata": {"name": "operator"}}`)},
+		"v1.8.2/README.md":                      {Data: []byte("# cert-manager")},
+		"v1.9.1/cluster-roles/cluster-role.yml": {Data: []byte(serviceAccount)},
+	}
+
+	versions, err := ReadEmbeddedVersions(embedded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var paths []string
+	for _, manifest := range versions["1.8.2"] {
+		paths = append(paths, manifest.Path)
+	}
+	expected := []string{"v1.8.2/crds/nested/list.yaml", "v1.8.2/roles/a.yml", "v1.8.2/roles/b.yml", "v1.8.2/service.json"}
+	if !reflect.DeepEqual(paths, expected) {
+		t.Errorf("expected every manifest of 1.8.2 in order, got %v", paths)
+	}
+	if len(versions) != 2 || len(versions["1.9.1"]) != 1 {
+		t.Errorf("expected both releases, got %v", versions)
+	}
+
+	embedded["v2.0.0/README.md"] = &fstest.MapFile{Data: []byte("# Not yet")}
+	if _, err := ReadEmbeddedVersions(embedded); err == nil || !strings.Contains(err.Error(), "v2.0.0") {
+		t.Errorf("expected an error naming the empty release, got %v", err)
+	}
+	if _, err := ReadEmbeddedVersions(fstest.MapFS{"notes.txt": {}}); err == nil {
+		t.Error("expected an error when no release is embedded")
+	}
+	if _, err := ReadEmbeddedFiles(embedded, "v3.0.0"); err == nil {
+		t.Error("expected an error for a missing directory")
+	}
+}
+
+func TestDecodeObjects(t *testing.T) {
+	manifest := Manifest{Path: "v1.8.2/bundle.yml", Content: []byte(`---
+apiVersion: v1
+kind: ServiceAccount
+metadata:
+  name: controller
+automountServiceAccountToken: true
+secrets:
+- name: token
+  index: 2
+  weight: 0.5
+---
+# Only a comment
+---
+apiVersion: v1
+kind: List
+items:
+- apiVersion: v1
+  kind: ConfigMap
+  metadata:
+    name: settings
+- apiVersion: v1
+  kind: Secret
+  metadata:
+    name: credentials
+---
+{"apiVersion": "v1", "kind": "Service", "metadata": {"name": "controller"}}
+`)}
+
+	objects, err := manifest.DecodeObjects()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var kinds []string
+	for _, object := range objects {
+		kinds = append(kinds, object.GetKind())
+	}
+	if !reflect.DeepEqual(kinds, []string{"ServiceAccount", "ConfigMap", "Secret", "Service"}) {
+		t.Errorf("unexpected objects %v", kinds)
+	}
+
+	// Integers come out as int64, like the objects the API machinery decodes.
+	secrets, _, _ := unstructured.NestedSlice(objects[0].Object, "secrets")
+	if secret := secrets[0].(map[string]interface{}); secret["index"] != int64(2) || secret["weight"] != 0.5 {
+		t.Errorf("expected an int64 and a float64, got %#v and %#v", secret["index"], secret["weight"])
+	}
+
+	for content, expected := range map[string]string{
+		"apiVersion: v1\nkind: ServiceAccount\n---\nkind: [ServiceAccount\n":                     "v1.8.2/broken.yml: document 1:",
+		"apiVersion: v1\nkind: ServiceAccount\n---\nmetadata:\n  name: x\n":                      "v1.8.2/broken.yml: document 1: the object has no kind",
+		"apiVersion: v1\nkind: List\nitems:\n- apiVersion: v1\n  kind: Secret\n- metadata: {}\n": "v1.8.2/broken.yml: document 0: item 1:",
+	} {
+		_, err := Manifest{Path: "v1.8.2/broken.yml", Content: []byte(content)}.DecodeObjects()
+		if err == nil || !strings.HasPrefix(err.Error(), expected) {
+			t.Errorf("expected an error starting with %q, got %v", expected, err)
+		}
+	}
+}